@@ -1,18 +1,49 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+
 	"github.com/cmpxNot29a/shurs/internal/app"
 	"github.com/cmpxNot29a/shurs/internal/config"
-	"log"
+	"github.com/cmpxNot29a/shurs/internal/logger"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run запускает приложение и возвращает код завершения процесса.
+func run() int {
 
-	conf := config.LoadConfig()
+	conf, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FATAL: Invalid configuration: %v\n", err)
+		return 2
+	}
+
+	appLogger, err := logger.New(os.Stderr, conf.LogLevel, conf.LogFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "FATAL: Invalid logger configuration: %v\n", err)
+		return 1
+	}
 
-	log.Printf("Configuration loaded: ServerAddress=%s, BaseURL=%s", conf.ServerAddress, conf.BaseURL)
+	appLogger.Info("Configuration loaded",
+		"server_address", conf.ServerAddress,
+		"base_url", conf.BaseURL,
+		"file_storage_path", conf.FileStoragePath,
+		"log_level", conf.LogLevel,
+		"log_format", conf.LogFormat,
+		"id_strategy", conf.IDStrategy,
+		"shutdown_timeout", conf.ShutdownTimeout,
+		"reap_interval", conf.ReapInterval,
+		"analytics_file_path", conf.AnalyticsFilePath,
+	)
 
-	if err := app.App(conf); err != nil {
-		log.Fatalf("FATAL: Application run failed: %v", err)
+	if err := app.App(context.Background(), conf, appLogger); err != nil {
+		appLogger.Error("Application run failed", "error", err)
+		return 1
 	}
+	return 0
 }
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cmpxNot29a/shurs/internal/helper"
+)
+
+const (
+	// MinAliasLength - наименьшая длина пользовательского алиаса.
+	MinAliasLength = 3
+	// MaxAliasLength - наибольшая длина пользовательского алиаса.
+	MaxAliasLength = MaxIDLength
+	// aliasAlphabet - символы, допустимые в алиасе.
+	aliasAlphabet = helper.Base62Alphabet + "-_"
+)
+
+// ErrInvalidAlias - алиас не прошел проверку ValidateAlias.
+var ErrInvalidAlias = errors.New("invalid alias")
+
+// reservedAliases - первые сегменты путей сервиса; алиасы с такими именами
+// перекрыли бы служебные маршруты. Сравнение без учета регистра.
+var reservedAliases = map[string]struct{}{
+	"api":     {},
+	"ping":    {},
+	"metrics": {},
+	"healthz": {},
+	"readyz":  {},
+}
+
+// aliasFormat - символы и длины алиасов в терминах IDFormat.
+var aliasFormat = IDFormat{Alphabet: aliasAlphabet, MinLength: MinAliasLength, MaxLength: MaxAliasLength}
+
+// ValidateAlias проверяет пользовательский алиас короткой ссылки: от MinAliasLength
+// до MaxAliasLength букв, цифр, "-" и "_", первый и последний символ - буква или цифра,
+// алиас не совпадает с зарезервированным словом. Ошибка оборачивает ErrInvalidAlias.
+func ValidateAlias(alias string) error {
+	if len(alias) < MinAliasLength || len(alias) > MaxAliasLength {
+		return fmt.Errorf("%w: length must be between %d and %d", ErrInvalidAlias, MinAliasLength, MaxAliasLength)
+	}
+	if !aliasFormat.Match(alias) {
+		return fmt.Errorf("%w: only letters, digits, \"-\" and \"_\" are allowed", ErrInvalidAlias)
+	}
+	if !isAlphanumeric(alias[0]) || !isAlphanumeric(alias[len(alias)-1]) {
+		return fmt.Errorf("%w: must start and end with a letter or digit", ErrInvalidAlias)
+	}
+	if _, reserved := reservedAliases[strings.ToLower(alias)]; reserved {
+		return fmt.Errorf("%w: %q is reserved", ErrInvalidAlias, alias)
+	}
+	return nil
+}
+
+// isAlphanumeric сообщает, является ли символ латинской буквой или цифрой.
+func isAlphanumeric(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
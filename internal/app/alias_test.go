@@ -0,0 +1,77 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cmpxNot29a/shurs/internal/auth"
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAlias(t *testing.T) {
+	testCases := []struct {
+		alias string
+		valid bool
+	}{
+		{alias: "spring-sale", valid: true},
+		{alias: "Sale_2025", valid: true},
+		{alias: "abc", valid: true},
+		{alias: strings.Repeat("a", MaxAliasLength), valid: true},
+		{alias: "ab", valid: false},
+		{alias: strings.Repeat("a", MaxAliasLength+1), valid: false},
+		{alias: "-sale", valid: false},
+		{alias: "sale_", valid: false},
+		{alias: "sale.2025", valid: false},
+		{alias: "распродажа", valid: false},
+		{alias: "api", valid: false},
+		{alias: "PING", valid: false},
+		{alias: "metrics", valid: false},
+		{alias: "healthz", valid: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.alias, func(t *testing.T) {
+			err := ValidateAlias(tc.alias)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidAlias)
+			}
+		})
+	}
+}
+
+func TestShortenerService_CreateLinkWithAlias(t *testing.T) {
+	storage := NewInMemoryStorage()
+	service := NewShortenerService(storage, newTestIDGenerator(t), 3, nil, nil, nil, logger.Discard())
+	ctx := auth.WithUserID(context.Background(), "user")
+
+	id, err := service.CreateLink(ctx, "https://yandex.ru/sale", LinkOptions{Alias: "spring-sale"})
+	require.NoError(t, err)
+	assert.Equal(t, "spring-sale", id)
+
+	url, err := service.GetOriginalURL(ctx, "spring-sale")
+	require.NoError(t, err)
+	assert.Equal(t, "https://yandex.ru/sale", url)
+
+	records, _, err := storage.GetByUser(ctx, "user", "", 10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "spring-sale", records[0].ID)
+
+	_, err = service.CreateLink(ctx, "https://yandex.ru/other", LinkOptions{Alias: "spring-sale"})
+	assert.ErrorIs(t, err, ErrConflict, "занятый алиас")
+
+	id, err = service.CreateLink(ctx, "https://yandex.ru/sale", LinkOptions{Alias: "summer-sale"})
+	assert.ErrorIs(t, err, ErrURLExists, "URL уже сокращен")
+	assert.Equal(t, "spring-sale", id)
+
+	_, err = service.CreateLink(ctx, "https://yandex.ru/other", LinkOptions{Alias: "api"})
+	assert.ErrorIs(t, err, ErrInvalidAlias)
+
+	require.NoError(t, service.DeleteUserURLs(ctx, []string{"spring-sale"}))
+	_, err = service.CreateLink(ctx, "https://yandex.ru/sale", LinkOptions{Alias: "spring-sale"})
+	assert.ErrorIs(t, err, ErrConflict, "алиас удаленной ссылки не переиспользуется")
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+)
+
+const (
+	// statsDayLayout - формат дня в посуточной статистике; дни считаются в UTC.
+	statsDayLayout = "2006-01-02"
+	// maxClickFieldLength - наибольшая длина referrer и user agent в событии; остальное обрезается.
+	maxClickFieldLength = 512
+	// visitorIDLength - число байт HMAC, оставляемых в идентификаторе посетителя.
+	visitorIDLength = 16
+)
+
+// ClickEvent - переход по короткой ссылке.
+type ClickEvent struct {
+	LinkID      string
+	LinkCreated time.Time // URLRecord.CreatedAt ссылки на момент перехода; нулевой - неизвестен
+	Time        time.Time
+	Referrer    string
+	UserAgent   string
+	VisitorID   string // HMAC IP-адреса клиента (см. VisitorID); сам адрес не хранится
+	OwnerID     string // Создатель ссылки на момент перехода; пустой - неизвестен
+}
+
+// LinkRef указывает ссылку в аналитике. ID удаленной истекшей ссылки освобождается
+// и может достаться новой ссылке, поэтому ссылку отличает еще и момент создания.
+type LinkRef struct {
+	ID        string
+	CreatedAt time.Time
+}
+
+// link возвращает ссылку, к которой относится переход.
+func (e ClickEvent) link() LinkRef {
+	return LinkRef{ID: e.LinkID, CreatedAt: e.LinkCreated}
+}
+
+// key возвращает ключ счетчиков ссылки. Для ссылки без момента создания ключ - ее ID.
+func (r LinkRef) key() string {
+	if r.CreatedAt.IsZero() {
+		return r.ID
+	}
+	return r.ID + "@" + strconv.FormatInt(r.CreatedAt.UnixNano(), 36)
+}
+
+// LinkStats - сводка переходов по ссылке.
+type LinkStats struct {
+	TotalClicks             int64
+	UniqueVisitors          int64
+	UniqueVisitorsEstimated bool          // UniqueVisitors - оценка, а не точное число (см. visitorCounter)
+	Daily                   []DailyClicks // дни с переходами по возрастанию даты
+}
+
+// DailyClicks - число переходов за день UTC.
+type DailyClicks struct {
+	Date   string // в формате statsDayLayout
+	Clicks int64
+}
+
+// Breakdown - разбивка переходов за период: самые частые браузеры, ОС, классы
+// устройств и домены источников, каждый список по убыванию числа переходов.
+type Breakdown struct {
+	TotalClicks int64
+	Browsers    []BreakdownItem
+	OS          []BreakdownItem
+	Devices     []BreakdownItem
+	Referrers   []BreakdownItem
+}
+
+// BreakdownItem - значение разбивки и число переходов с ним.
+type BreakdownItem struct {
+	Name   string
+	Clicks int64
+}
+
+// ReportQuery - параметры отчета: дни UTC с From по To включительно и размер списков.
+type ReportQuery struct {
+	From time.Time
+	To   time.Time
+	Top  int
+}
+
+// AnalyticsStore хранит переходы по ссылкам.
+// SaveClicks сохраняет пачку событий; Stats возвращает сводку по ссылке,
+// для ссылки без переходов - нулевую. Статистика привязана к LinkRef: если ID
+// освободится и достанется новой ссылке, ее статистика начнется с нуля.
+// LinkReport и OwnerReport возвращают разбивку переходов по ссылке и по всем
+// ссылкам создателя (ClickEvent.OwnerID) за период query.
+type AnalyticsStore interface {
+	SaveClicks(ctx context.Context, events []ClickEvent) error
+	Stats(ctx context.Context, link LinkRef) (LinkStats, error)
+	LinkReport(ctx context.Context, link LinkRef, query ReportQuery) (Breakdown, error)
+	OwnerReport(ctx context.Context, ownerID string, query ReportQuery) (Breakdown, error)
+	Close() error
+}
+
+// VisitorID возвращает идентификатор посетителя по IP-адресу клиента: HMAC-SHA256
+// с ключом key, усеченный до visitorIDLength байт. Без ключа по идентификатору
+// нельзя перебором восстановить адрес.
+func VisitorID(key []byte, clientIP string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(clientIP))
+	return hex.EncodeToString(mac.Sum(nil)[:visitorIDLength])
+}
+
+// truncateClickField обрезает значение заголовка до maxClickFieldLength байт.
+func truncateClickField(value string) string {
+	if len(value) > maxClickFieldLength {
+		return value[:maxClickFieldLength]
+	}
+	return value
+}
@@ -0,0 +1,154 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// clickRecord - одна строка файла аналитики в формате JSON Lines.
+type clickRecord struct {
+	LinkID      string    `json:"link_id"`
+	LinkCreated time.Time `json:"link_created,omitzero"`
+	Time        time.Time `json:"time"`
+	Referrer    string    `json:"referrer,omitempty"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	VisitorID   string    `json:"visitor_id,omitempty"`
+	OwnerID     string    `json:"owner_id,omitempty"`
+}
+
+// FileAnalytics дописывает события переходов в файл и считает статистику в памяти.
+// При создании события из файла заново учитываются в памяти.
+type FileAnalytics struct {
+	mu     sync.Mutex // сериализует запись в файл
+	memory *InMemoryAnalytics
+	file   *os.File
+	writer *bufio.Writer
+	logger *slog.Logger
+}
+
+// NewFileAnalytics открывает (или создает) файл аналитики и восстанавливает из него статистику.
+// Поврежденные строки пропускаются с предупреждением.
+func NewFileAnalytics(path string, logger *slog.Logger) (*FileAnalytics, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open analytics file %s: %w", path, err)
+	}
+
+	a := &FileAnalytics{
+		memory: NewInMemoryAnalytics(),
+		file:   file,
+		logger: logger.With("component", "analytics.file"),
+	}
+
+	needsNewline, err := a.restore()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to restore analytics from %s: %w", path, err)
+	}
+
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek analytics file %s: %w", path, err)
+	}
+	a.writer = bufio.NewWriter(file)
+
+	// Обрезанная последняя строка не должна склеиться со следующим событием.
+	if needsNewline {
+		if err := a.writer.WriteByte('\n'); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to terminate analytics file %s: %w", path, err)
+		}
+		if err := a.writer.Flush(); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to terminate analytics file %s: %w", path, err)
+		}
+	}
+
+	return a, nil
+}
+
+// restore читает файл построчно и учитывает события в памяти.
+// Возвращает true, если файл не заканчивается переводом строки.
+func (a *FileAnalytics) restore() (bool, error) {
+	reader := bufio.NewReader(a.file)
+	lineNum := 0
+	restored := 0
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			lineNum++
+			var rec clickRecord
+			if jsonErr := json.Unmarshal(line, &rec); jsonErr != nil || rec.LinkID == "" {
+				a.logger.Warn("Skipping corrupt line", "line", lineNum, "error", jsonErr)
+			} else {
+				a.memory.add(ClickEvent(rec))
+				restored++
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			a.logger.Info("Analytics restored", "events", restored, "path", a.file.Name())
+			return len(line) > 0, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// SaveClicks реализует метод интерфейса AnalyticsStore. Пачка записывается
+// в файл одним сбросом буфера и только затем учитывается в памяти.
+func (a *FileAnalytics) SaveClicks(ctx context.Context, events []ClickEvent) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, event := range events {
+		data, err := json.Marshal(clickRecord(event))
+		if err != nil {
+			return fmt.Errorf("failed to encode click: %w", err)
+		}
+		data = append(data, '\n')
+		if _, err := a.writer.Write(data); err != nil {
+			return fmt.Errorf("failed to write clicks to analytics file: %w", err)
+		}
+	}
+	if err := a.writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write clicks to analytics file: %w", err)
+	}
+	return a.memory.SaveClicks(ctx, events)
+}
+
+// Stats реализует метод интерфейса AnalyticsStore.
+func (a *FileAnalytics) Stats(ctx context.Context, link LinkRef) (LinkStats, error) {
+	return a.memory.Stats(ctx, link)
+}
+
+// LinkReport реализует метод интерфейса AnalyticsStore.
+func (a *FileAnalytics) LinkReport(ctx context.Context, link LinkRef, query ReportQuery) (Breakdown, error) {
+	return a.memory.LinkReport(ctx, link, query)
+}
+
+// OwnerReport реализует метод интерфейса AnalyticsStore.
+func (a *FileAnalytics) OwnerReport(ctx context.Context, ownerID string, query ReportQuery) (Breakdown, error) {
+	return a.memory.OwnerReport(ctx, ownerID, query)
+}
+
+// Close реализует метод интерфейса AnalyticsStore.
+func (a *FileAnalytics) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.writer.Flush(); err != nil {
+		a.file.Close()
+		return fmt.Errorf("failed to flush analytics file: %w", err)
+	}
+	return a.file.Close()
+}
@@ -0,0 +1,221 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// maxDayValues - наибольшее число различных значений измерения за день; переходы
+// с новыми значениями сверх него учитываются под otherValue.
+const maxDayValues = 100
+
+// Измерения разбивки переходов.
+const (
+	dimBrowser = iota
+	dimOS
+	dimDevice
+	dimReferrer
+	dimensionCount
+)
+
+// dayClicks - переходы за один день: всего и по значениям каждого измерения.
+type dayClicks struct {
+	total  int64
+	values [dimensionCount]map[string]int64
+}
+
+// dailyClicks - счетчики по дням; ключ - день в формате statsDayLayout.
+type dailyClicks map[string]*dayClicks
+
+// add учитывает переход со значениями измерений values за день day. Число значений
+// измерения за день ограничено maxDayValues: источники переходов не ограничены ничем.
+func (d dailyClicks) add(day string, values [dimensionCount]string) {
+	counts, exists := d[day]
+	if !exists {
+		counts = &dayClicks{}
+		for i := range counts.values {
+			counts.values[i] = make(map[string]int64)
+		}
+		d[day] = counts
+	}
+	counts.total++
+	for i, value := range values {
+		if _, seen := counts.values[i][value]; !seen && len(counts.values[i]) >= maxDayValues {
+			value = otherValue
+		}
+		counts.values[i][value]++
+	}
+}
+
+// breakdown складывает счетчики дней из query и оставляет по query.Top самых частых
+// значений каждого измерения. Просматриваются только дни периода, а не события.
+func (d dailyClicks) breakdown(query ReportQuery) Breakdown {
+	var (
+		total  int64
+		merged [dimensionCount]map[string]int64
+	)
+	for i := range merged {
+		merged[i] = make(map[string]int64)
+	}
+	last := query.To.UTC().Format(statsDayLayout)
+	for day := query.From.UTC(); ; day = day.AddDate(0, 0, 1) {
+		key := day.Format(statsDayLayout)
+		if key > last {
+			break
+		}
+		counts, exists := d[key]
+		if !exists {
+			continue
+		}
+		total += counts.total
+		for i, values := range counts.values {
+			for value, clicks := range values {
+				merged[i][value] += clicks
+			}
+		}
+	}
+
+	return Breakdown{
+		TotalClicks: total,
+		Browsers:    topItems(merged[dimBrowser], query.Top),
+		OS:          topItems(merged[dimOS], query.Top),
+		Devices:     topItems(merged[dimDevice], query.Top),
+		Referrers:   topItems(merged[dimReferrer], query.Top),
+	}
+}
+
+// topItems возвращает не больше top значений по убыванию числа переходов,
+// при равенстве - по имени.
+func topItems(counts map[string]int64, top int) []BreakdownItem {
+	items := make([]BreakdownItem, 0, len(counts))
+	for name, clicks := range counts {
+		items = append(items, BreakdownItem{Name: name, Clicks: clicks})
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Clicks != items[j].Clicks {
+			return items[i].Clicks > items[j].Clicks
+		}
+		return items[i].Name < items[j].Name
+	})
+	if len(items) > top {
+		items = items[:top]
+	}
+	return items
+}
+
+// linkClicks - накопленная статистика одной ссылки.
+type linkClicks struct {
+	total    int64
+	visitors *visitorCounter
+	days     dailyClicks
+}
+
+// InMemoryAnalytics хранит не сами события, а накопленные по ним счетчики:
+// сводки и отчеты собираются без просмотра истории переходов. Счетчики
+// ведутся по дням, отдельно для каждой ссылки и каждого создателя ссылок.
+type InMemoryAnalytics struct {
+	mu     sync.RWMutex
+	links  map[string]*linkClicks // ключ - LinkRef.key
+	owners map[string]dailyClicks
+}
+
+func NewInMemoryAnalytics() *InMemoryAnalytics {
+	return &InMemoryAnalytics{
+		links:  make(map[string]*linkClicks),
+		owners: make(map[string]dailyClicks),
+	}
+}
+
+// SaveClicks реализует метод интерфейса AnalyticsStore.
+func (a *InMemoryAnalytics) SaveClicks(ctx context.Context, events []ClickEvent) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, event := range events {
+		a.add(event)
+	}
+	return nil
+}
+
+// add учитывает событие в счетчиках ссылки и ее создателя; вызывается под a.mu.
+func (a *InMemoryAnalytics) add(event ClickEvent) {
+	key := event.link().key()
+	link, exists := a.links[key]
+	if !exists {
+		link = &linkClicks{
+			visitors: newVisitorCounter(),
+			days:     make(dailyClicks),
+		}
+		a.links[key] = link
+	}
+	link.total++
+	if event.VisitorID != "" {
+		link.visitors.add(event.VisitorID)
+	}
+
+	day := event.Time.UTC().Format(statsDayLayout)
+	ua := ParseUserAgent(event.UserAgent)
+	values := [dimensionCount]string{
+		dimBrowser:  ua.Browser,
+		dimOS:       ua.OS,
+		dimDevice:   ua.Device,
+		dimReferrer: ReferrerDomain(event.Referrer),
+	}
+	link.days.add(day, values)
+
+	if event.OwnerID != "" {
+		owner, exists := a.owners[event.OwnerID]
+		if !exists {
+			owner = make(dailyClicks)
+			a.owners[event.OwnerID] = owner
+		}
+		owner.add(day, values)
+	}
+}
+
+// Stats реализует метод интерфейса AnalyticsStore.
+func (a *InMemoryAnalytics) Stats(ctx context.Context, ref LinkRef) (LinkStats, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	link, exists := a.links[ref.key()]
+	if !exists {
+		return LinkStats{}, nil
+	}
+	stats := LinkStats{
+		TotalClicks: link.total,
+		Daily:       make([]DailyClicks, 0, len(link.days)),
+	}
+	stats.UniqueVisitors, stats.UniqueVisitorsEstimated = link.visitors.count()
+	for date, counts := range link.days {
+		stats.Daily = append(stats.Daily, DailyClicks{Date: date, Clicks: counts.total})
+	}
+	sort.Slice(stats.Daily, func(i, j int) bool { return stats.Daily[i].Date < stats.Daily[j].Date })
+	return stats, nil
+}
+
+// LinkReport реализует метод интерфейса AnalyticsStore.
+func (a *InMemoryAnalytics) LinkReport(ctx context.Context, ref LinkRef, query ReportQuery) (Breakdown, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	link, exists := a.links[ref.key()]
+	if !exists {
+		return dailyClicks(nil).breakdown(query), nil
+	}
+	return link.days.breakdown(query), nil
+}
+
+// OwnerReport реализует метод интерфейса AnalyticsStore.
+func (a *InMemoryAnalytics) OwnerReport(ctx context.Context, ownerID string, query ReportQuery) (Breakdown, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.owners[ownerID].breakdown(query), nil
+}
+
+// Close реализует метод интерфейса AnalyticsStore.
+func (a *InMemoryAnalytics) Close() error {
+	return nil
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Значения разбивки для переходов без данных.
+const (
+	unknownValue   = "Unknown"
+	otherValue     = "Other"
+	directReferrer = "direct" // переход без заголовка Referer
+)
+
+// Классы устройств.
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+)
+
+// UserAgentInfo - разобранный заголовок User-Agent.
+type UserAgentInfo struct {
+	Browser string
+	OS      string
+	Device  string
+}
+
+// uaRule сопоставляет подстроке User-Agent имя браузера или ОС.
+type uaRule struct {
+	marker string
+	name   string
+}
+
+// browserRules проверяются по порядку: браузеры на Chromium упоминают и Chrome,
+// и Safari, поэтому более точные маркеры идут первыми.
+var browserRules = []uaRule{
+	{"Edg/", "Edge"},
+	{"Edge/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Opera", "Opera"},
+	{"YaBrowser/", "Yandex Browser"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"Firefox/", "Firefox"},
+	{"FxiOS/", "Firefox"},
+	{"CriOS/", "Chrome"},
+	{"Chrome/", "Chrome"},
+	{"Chromium/", "Chrome"},
+	{"Version/", "Safari"},
+	{"Trident/", "Internet Explorer"},
+	{"MSIE ", "Internet Explorer"},
+}
+
+// osRules проверяются по порядку: iOS называет себя "like Mac OS X", Android - Linux.
+var osRules = []uaRule{
+	{"Windows", "Windows"},
+	{"iPhone", "iOS"},
+	{"iPad", "iOS"},
+	{"iPod", "iOS"},
+	{"Android", "Android"},
+	{"CrOS", "Chrome OS"},
+	{"Mac OS X", "macOS"},
+	{"Macintosh", "macOS"},
+	{"Linux", "Linux"},
+}
+
+// botMarkers - подстроки User-Agent роботов и HTTP-клиентов, в нижнем регистре.
+var botMarkers = []string{"bot", "crawler", "spider", "slurp", "curl/", "wget/", "python-requests", "go-http-client"}
+
+// ParseUserAgent определяет по заголовку User-Agent браузер, ОС и класс устройства.
+// Разбор эвристический: неизвестные браузеры и ОС попадают в "Other",
+// пустой заголовок - в "Unknown".
+func ParseUserAgent(userAgent string) UserAgentInfo {
+	if userAgent == "" {
+		return UserAgentInfo{Browser: unknownValue, OS: unknownValue, Device: unknownValue}
+	}
+	return UserAgentInfo{
+		Browser: matchUserAgent(userAgent, browserRules),
+		OS:      matchUserAgent(userAgent, osRules),
+		Device:  deviceClass(userAgent),
+	}
+}
+
+func matchUserAgent(userAgent string, rules []uaRule) string {
+	for _, rule := range rules {
+		if strings.Contains(userAgent, rule.marker) {
+			return rule.name
+		}
+	}
+	return otherValue
+}
+
+// deviceClass относит User-Agent к роботам, планшетам, телефонам или компьютерам.
+// Android без "Mobile" - планшет, как того требуют правила Google для User-Agent.
+func deviceClass(userAgent string) string {
+	lower := strings.ToLower(userAgent)
+	for _, marker := range botMarkers {
+		if strings.Contains(lower, marker) {
+			return DeviceBot
+		}
+	}
+	switch {
+	case strings.Contains(userAgent, "iPad"), strings.Contains(userAgent, "Tablet"):
+		return DeviceTablet
+	case strings.Contains(userAgent, "Mobi"), strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPod"):
+		return DeviceMobile
+	case strings.Contains(userAgent, "Android"):
+		return DeviceTablet
+	}
+	return DeviceDesktop
+}
+
+// ReferrerDomain приводит заголовок Referer к домену без "www." и порта в нижнем регистре.
+// Пустой заголовок - "direct", неразборчивый - "Unknown".
+func ReferrerDomain(referrer string) string {
+	if referrer == "" {
+		return directReferrer
+	}
+	u, err := url.Parse(referrer)
+	if err != nil {
+		return unknownValue
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	host = strings.TrimPrefix(host, "www.")
+	if host == "" {
+		return unknownValue
+	}
+	return host
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	testCases := []struct {
+		name      string
+		userAgent string
+		expected  UserAgentInfo
+	}{
+		{
+			name:      "Empty",
+			userAgent: "",
+			expected:  UserAgentInfo{Browser: unknownValue, OS: unknownValue, Device: unknownValue},
+		},
+		{
+			name:      "Chrome On Windows",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36",
+			expected:  UserAgentInfo{Browser: "Chrome", OS: "Windows", Device: DeviceDesktop},
+		},
+		{
+			name:      "Edge Is Not Chrome",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36 Edg/129.0.0.0",
+			expected:  UserAgentInfo{Browser: "Edge", OS: "Windows", Device: DeviceDesktop},
+		},
+		{
+			name:      "Firefox On Linux",
+			userAgent: "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0",
+			expected:  UserAgentInfo{Browser: "Firefox", OS: "Linux", Device: DeviceDesktop},
+		},
+		{
+			name:      "Safari On macOS",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.0 Safari/605.1.15",
+			expected:  UserAgentInfo{Browser: "Safari", OS: "macOS", Device: DeviceDesktop},
+		},
+		{
+			name:      "Safari On iPhone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 18_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/18.0 Mobile/15E148 Safari/604.1",
+			expected:  UserAgentInfo{Browser: "Safari", OS: "iOS", Device: DeviceMobile},
+		},
+		{
+			name:      "Safari On iPad",
+			userAgent: "Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+			expected:  UserAgentInfo{Browser: "Safari", OS: "iOS", Device: DeviceTablet},
+		},
+		{
+			name:      "Chrome On Android Phone",
+			userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Mobile Safari/537.36",
+			expected:  UserAgentInfo{Browser: "Chrome", OS: "Android", Device: DeviceMobile},
+		},
+		{
+			name:      "Android Tablet",
+			userAgent: "Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36",
+			expected:  UserAgentInfo{Browser: "Chrome", OS: "Android", Device: DeviceTablet},
+		},
+		{
+			name:      "Search Bot",
+			userAgent: "Mozilla/5.0 (compatible; YandexBot/3.0; +http://yandex.com/bots)",
+			expected:  UserAgentInfo{Browser: otherValue, OS: otherValue, Device: DeviceBot},
+		},
+		{
+			name:      "Curl",
+			userAgent: "curl/8.5.0",
+			expected:  UserAgentInfo{Browser: otherValue, OS: otherValue, Device: DeviceBot},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ParseUserAgent(tc.userAgent))
+		})
+	}
+}
+
+func TestReferrerDomain(t *testing.T) {
+	testCases := []struct {
+		referrer string
+		expected string
+	}{
+		{"", directReferrer},
+		{"https://ya.ru/search?text=go", "ya.ru"},
+		{"https://www.Google.com/", "google.com"},
+		{"http://news.example.org:8080/a", "news.example.org"},
+		{"https://example.com./", "example.com"},
+		{"not a url", unknownValue},
+		{"://bad", unknownValue},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.referrer, func(t *testing.T) {
+			assert.Equal(t, tc.expected, ReferrerDomain(tc.referrer))
+		})
+	}
+}
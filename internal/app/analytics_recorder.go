@@ -0,0 +1,197 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// clicksQueueSize - емкость очереди событий переходов.
+	clicksQueueSize = 4096
+	// clicksBatchSize - число накопленных событий, после которого пачка сбрасывается в хранилище.
+	clicksBatchSize = 500
+	// clicksFlushInterval - наибольшее время ожидания события в очереди.
+	clicksFlushInterval = time.Second
+	// resolveLinksTimeout ограничивает поиск записей ссылок для одной пачки событий.
+	resolveLinksTimeout = 5 * time.Second
+)
+
+// ClickRecorder сохраняет события переходов в фоне, чтобы запись аналитики не
+// задерживала перенаправление. События копятся в очереди и сбрасываются в
+// AnalyticsStore пачками: по достижении batchSize или раз в flushInterval.
+// Если очередь переполнена, событие отбрасывается. Shutdown дожидается сброса
+// всех принятых событий. Перед сохранением события получают OwnerID и LinkCreated
+// из links, по одному запросу на ссылку в пачке; без links они не заполняются.
+// События, ссылку которых найти не удалось, отбрасываются: без LinkCreated их не
+// показала бы ни одна сводка.
+type ClickRecorder struct {
+	store         AnalyticsStore
+	links         Storage
+	key           []byte // ключ VisitorID
+	events        chan ClickEvent
+	batchSize     int
+	flushInterval time.Duration
+	mu            sync.RWMutex // защищает closed от гонки с закрытием events
+	closed        bool
+	done          chan struct{}
+	metrics       *Metrics
+	logger        *slog.Logger
+}
+
+// NewClickRecorder создает регистратор переходов и запускает его рабочую горутину.
+func NewClickRecorder(store AnalyticsStore, links Storage, key []byte, batchSize int, flushInterval time.Duration,
+	metrics *Metrics, logger *slog.Logger) *ClickRecorder {
+	c := &ClickRecorder{
+		store:         store,
+		links:         links,
+		key:           key,
+		events:        make(chan ClickEvent, clicksQueueSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+		metrics:       metrics,
+		logger:        logger.With("component", "clicks"),
+	}
+	go c.run()
+	return c
+}
+
+// Record ставит событие в очередь, заменяя clientIP идентификатором посетителя.
+// Никогда не блокируется: при переполненной очереди или после начала остановки
+// событие отбрасывается.
+func (c *ClickRecorder) Record(event ClickEvent, clientIP string) {
+	event.Referrer = truncateClickField(event.Referrer)
+	event.UserAgent = truncateClickField(event.UserAgent)
+	if clientIP != "" {
+		event.VisitorID = VisitorID(c.key, clientIP)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.closed {
+		return
+	}
+	select {
+	case c.events <- event:
+	default:
+		c.metrics.clickDropped()
+		c.logger.Debug("Click queue is full, dropping event", "id", event.LinkID)
+	}
+}
+
+// Shutdown перестает принимать события и ждет, пока очередь будет сброшена в хранилище.
+func (c *ClickRecorder) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.events)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *ClickRecorder) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.flushInterval)
+	defer ticker.Stop()
+
+	pending := make([]ClickEvent, 0, c.batchSize)
+	for {
+		select {
+		case event, ok := <-c.events:
+			if !ok {
+				c.flush(pending)
+				return
+			}
+			pending = append(pending, event)
+			if len(pending) >= c.batchSize {
+				pending = c.flush(pending)
+			}
+		case <-ticker.C:
+			pending = c.flush(pending)
+		}
+	}
+}
+
+// flush сохраняет накопленные события и возвращает опустошенный буфер.
+// Пачка, которую не удалось сохранить, теряется: аналитика не должна копиться без предела.
+func (c *ClickRecorder) flush(pending []ClickEvent) []ClickEvent {
+	if len(pending) == 0 {
+		return pending
+	}
+	events := c.resolveLinks(pending)
+	if len(events) == 0 {
+		return pending[:0]
+	}
+	if err := c.store.SaveClicks(context.Background(), events); err != nil {
+		c.logger.Error("Failed to save clicks", "count", len(events), "error", err)
+	} else {
+		c.logger.Debug("Clicks saved", "count", len(events))
+	}
+	return pending[:0]
+}
+
+// resolveLinks заполняет OwnerID и LinkCreated событий по записям ссылок и возвращает
+// события, ссылки которых нашлись, переиспользуя events. Удаленная или истекшая ссылка
+// тоже находится. События несуществующей ссылки, ссылки, которую не удалось прочитать,
+// и ссылок, до которых не дошла очередь за resolveLinksTimeout, отбрасываются и
+// учитываются в метриках. Ограничение времени не дает медленному хранилищу
+// остановить сброс очереди.
+func (c *ClickRecorder) resolveLinks(events []ClickEvent) []ClickEvent {
+	if c.links == nil {
+		return events
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resolveLinksTimeout)
+	defer cancel()
+
+	type resolution struct {
+		rec   URLRecord
+		found bool
+	}
+	records := make(map[string]resolution)
+	resolved := events[:0]
+	for _, event := range events {
+		link, cached := records[event.LinkID]
+		if !cached {
+			link.rec, link.found = c.resolveLink(ctx, event.LinkID)
+			records[event.LinkID] = link
+		}
+		if !link.found {
+			c.metrics.clickUnresolved()
+			continue
+		}
+		event.OwnerID = link.rec.UserID
+		event.LinkCreated = link.rec.CreatedAt
+		resolved = append(resolved, event)
+	}
+	if dropped := len(events) - len(resolved); dropped > 0 {
+		c.logger.Warn("Dropping clicks of unresolved links", "count", dropped, "timed_out", ctx.Err() != nil)
+	}
+	return resolved
+}
+
+// resolveLink ищет запись ссылки id; после истечения ctx ссылки больше не ищутся.
+func (c *ClickRecorder) resolveLink(ctx context.Context, id string) (URLRecord, bool) {
+	if ctx.Err() != nil {
+		return URLRecord{}, false
+	}
+	rec, err := c.links.GetRecord(ctx, id)
+	if err == nil || isUnavailableLink(err) && !errors.Is(err, ErrNotFound) {
+		return rec, true
+	}
+	if !errors.Is(err, ErrNotFound) && ctx.Err() == nil {
+		c.logger.Warn("Failed to resolve link", "id", id, "error", err)
+	}
+	return URLRecord{}, false
+}
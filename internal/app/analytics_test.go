@@ -0,0 +1,375 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cmpxNot29a/shurs/internal/auth"
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/cmpxNot29a/shurs/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testChromeUA  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/129.0.0.0 Safari/537.36"
+	testFirefoxUA = "Mozilla/5.0 (X11; Linux x86_64; rv:131.0) Gecko/20100101 Firefox/131.0"
+)
+
+// testAnalyticsStore проверяет сводку и отчеты переходов для любой реализации AnalyticsStore.
+func testAnalyticsStore(t *testing.T, a AnalyticsStore) {
+	t.Helper()
+	ctx := context.Background()
+	day1 := time.Date(2026, 10, 17, 23, 30, 0, 0, time.UTC)
+	day2 := day1.Add(time.Hour)
+
+	require.NoError(t, a.SaveClicks(ctx, []ClickEvent{
+		{LinkID: "abcdef12", Time: day2, VisitorID: "v1", UserAgent: testChromeUA, OwnerID: "owner"},
+		{LinkID: "abcdef12", Time: day1, VisitorID: "v1", UserAgent: testFirefoxUA, OwnerID: "owner"},
+		{LinkID: "abcdef12", Time: day2, VisitorID: "v2", UserAgent: testChromeUA, OwnerID: "owner",
+			Referrer: "https://www.ya.ru/search"},
+		{LinkID: "zyxwvu98", Time: day1, VisitorID: "v1", OwnerID: "owner"},
+	}))
+
+	stats, err := a.Stats(ctx, LinkRef{ID: "abcdef12"})
+	require.NoError(t, err)
+	assert.Equal(t, LinkStats{
+		TotalClicks:    3,
+		UniqueVisitors: 2,
+		Daily:          []DailyClicks{{Date: "2026-10-17", Clicks: 1}, {Date: "2026-10-18", Clicks: 2}},
+	}, stats)
+
+	stats, err = a.Stats(ctx, LinkRef{ID: "notexist"})
+	require.NoError(t, err)
+	assert.Zero(t, stats.TotalClicks)
+	assert.Empty(t, stats.Daily)
+
+	report, err := a.LinkReport(ctx, LinkRef{ID: "abcdef12"}, ReportQuery{From: day1, To: day2, Top: 10})
+	require.NoError(t, err)
+	assert.Equal(t, Breakdown{
+		TotalClicks: 3,
+		Browsers:    []BreakdownItem{{Name: "Chrome", Clicks: 2}, {Name: "Firefox", Clicks: 1}},
+		OS:          []BreakdownItem{{Name: "Windows", Clicks: 2}, {Name: "Linux", Clicks: 1}},
+		Devices:     []BreakdownItem{{Name: DeviceDesktop, Clicks: 3}},
+		Referrers:   []BreakdownItem{{Name: directReferrer, Clicks: 2}, {Name: "ya.ru", Clicks: 1}},
+	}, report)
+
+	// Окно из одного дня; при равенстве числа переходов побеждает имя.
+	report, err = a.LinkReport(ctx, LinkRef{ID: "abcdef12"}, ReportQuery{From: day2, To: day2, Top: 1})
+	require.NoError(t, err)
+	assert.Equal(t, Breakdown{
+		TotalClicks: 2,
+		Browsers:    []BreakdownItem{{Name: "Chrome", Clicks: 2}},
+		OS:          []BreakdownItem{{Name: "Windows", Clicks: 2}},
+		Devices:     []BreakdownItem{{Name: DeviceDesktop, Clicks: 2}},
+		Referrers:   []BreakdownItem{{Name: directReferrer, Clicks: 1}},
+	}, report)
+
+	report, err = a.OwnerReport(ctx, "owner", ReportQuery{From: day1, To: day2, Top: 10})
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, report.TotalClicks)
+	assert.Equal(t, []BreakdownItem{{Name: "Chrome", Clicks: 2}, {Name: "Firefox", Clicks: 1}, {Name: unknownValue, Clicks: 1}},
+		report.Browsers)
+	assert.Equal(t, []BreakdownItem{{Name: directReferrer, Clicks: 3}, {Name: "ya.ru", Clicks: 1}}, report.Referrers)
+
+	report, err = a.OwnerReport(ctx, "owner", ReportQuery{From: day1.AddDate(0, 0, -7), To: day1.AddDate(0, 0, -1), Top: 10})
+	require.NoError(t, err)
+	assert.Zero(t, report.TotalClicks, "переходы вне окна не учитываются")
+	assert.Empty(t, report.Browsers)
+
+	report, err = a.OwnerReport(ctx, "nobody", ReportQuery{From: day1, To: day2, Top: 10})
+	require.NoError(t, err)
+	assert.Zero(t, report.TotalClicks)
+	assert.Empty(t, report.Referrers)
+}
+
+func TestInMemoryAnalytics(t *testing.T) {
+	testAnalyticsStore(t, NewInMemoryAnalytics())
+}
+
+func TestInMemoryAnalytics_BoundsCardinality(t *testing.T) {
+	ctx := context.Background()
+	a := NewInMemoryAnalytics()
+	now := time.Now()
+
+	const visitors = 20000
+	events := make([]ClickEvent, 0, visitors+maxDayValues+50)
+	for i := range maxDayValues + 50 {
+		events = append(events, ClickEvent{LinkID: "abcdef12", Time: now, Referrer: fmt.Sprintf("https://site%d.example/", i)})
+	}
+	for i := range visitors {
+		events = append(events, ClickEvent{LinkID: "zyxwvu98", Time: now, VisitorID: fmt.Sprintf("v%d", i)})
+	}
+	require.NoError(t, a.SaveClicks(ctx, events))
+
+	report, err := a.LinkReport(ctx, LinkRef{ID: "abcdef12"}, ReportQuery{From: now, To: now, Top: 1000})
+	require.NoError(t, err)
+	assert.EqualValues(t, maxDayValues+50, report.TotalClicks, "переходы не теряются")
+	assert.Len(t, report.Referrers, maxDayValues+1, "новые источники сверх предела сворачиваются в Other")
+	assert.Contains(t, report.Referrers, BreakdownItem{Name: otherValue, Clicks: 50})
+
+	stats, err := a.Stats(ctx, LinkRef{ID: "zyxwvu98"})
+	require.NoError(t, err)
+	assert.EqualValues(t, visitors, stats.TotalClicks)
+	assert.True(t, stats.UniqueVisitorsEstimated, "сверх exactVisitorsLimit число посетителей оценивается")
+	assert.InEpsilon(t, visitors, stats.UniqueVisitors, 0.05, "оценка не замирает на пределе")
+}
+
+func TestVisitorCounter(t *testing.T) {
+	v := newVisitorCounter()
+	for i := range exactVisitorsLimit {
+		v.add(fmt.Sprintf("v%d", i))
+		v.add(fmt.Sprintf("v%d", i))
+	}
+	n, estimated := v.count()
+	assert.False(t, estimated)
+	assert.EqualValues(t, exactVisitorsLimit, n, "до предела подсчет точный")
+
+	for i := range 1000 {
+		v.add(fmt.Sprintf("v%d", i))
+	}
+	n, estimated = v.count()
+	assert.True(t, estimated)
+	assert.InEpsilon(t, 1000, n, 0.05, "повторные посетители не увеличивают оценку")
+	assert.Len(t, v.registers, 1<<visitorsPrecision, "память оценки не растет с числом посетителей")
+}
+
+func TestFileAnalytics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clicks.json")
+	a, err := NewFileAnalytics(path, logger.Discard())
+	require.NoError(t, err)
+	testAnalyticsStore(t, a)
+	require.NoError(t, a.Close())
+
+	// Поврежденная строка пропускается, остальные события восстанавливаются.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString("{not json\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	a, err = NewFileAnalytics(path, logger.Discard())
+	require.NoError(t, err)
+	defer a.Close()
+	stats, err := a.Stats(context.Background(), LinkRef{ID: "abcdef12"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, stats.TotalClicks)
+	assert.EqualValues(t, 2, stats.UniqueVisitors)
+	day := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	report, err := a.OwnerReport(context.Background(), "owner", ReportQuery{From: day.AddDate(0, 0, -1), To: day, Top: 10})
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, report.TotalClicks, "создатель ссылки восстанавливается из файла")
+}
+
+func TestVisitorID(t *testing.T) {
+	id := VisitorID([]byte("key"), "203.0.113.7")
+	assert.Len(t, id, 2*visitorIDLength)
+	assert.NotContains(t, id, "203.0.113.7")
+	assert.Equal(t, id, VisitorID([]byte("key"), "203.0.113.7"))
+	assert.NotEqual(t, id, VisitorID([]byte("key"), "203.0.113.8"))
+	assert.NotEqual(t, id, VisitorID([]byte("other"), "203.0.113.7"), "без ключа идентификатор не воспроизвести")
+}
+
+func TestClickRecorder_FlushesOnShutdown(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryAnalytics()
+
+	// Пачка и таймер заведомо не срабатывают: сохранить события должен только Shutdown.
+	clicks := NewClickRecorder(store, nil, []byte("key"), 1000, time.Hour, nil, logger.Discard())
+	clicks.Record(ClickEvent{LinkID: "abcdef12", Time: time.Now()}, "203.0.113.7")
+	clicks.Record(ClickEvent{LinkID: "abcdef12", Time: time.Now()}, "203.0.113.7")
+	clicks.Record(ClickEvent{LinkID: "abcdef12", Time: time.Now()}, "203.0.113.8")
+	require.NoError(t, clicks.Shutdown(ctx))
+
+	stats, err := store.Stats(ctx, LinkRef{ID: "abcdef12"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, stats.TotalClicks)
+	assert.EqualValues(t, 2, stats.UniqueVisitors)
+
+	clicks.Record(ClickEvent{LinkID: "abcdef12", Time: time.Now()}, "203.0.113.7")
+	require.NoError(t, clicks.Shutdown(ctx), "повторная остановка безопасна")
+	stats, err = store.Stats(ctx, LinkRef{ID: "abcdef12"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, stats.TotalClicks, "после остановки события отбрасываются")
+}
+
+func TestClickRecorder_ResolvesLinks(t *testing.T) {
+	ctx := context.Background()
+	storage := NewInMemoryStorage()
+	require.NoError(t, storage.Save(ctx, URLRecord{ID: "abcdef12", OriginalURL: "https://yandex.ru", UserID: "owner"}))
+	require.NoError(t, storage.Save(ctx, URLRecord{ID: "zyxwvu98", OriginalURL: "https://ya.ru", UserID: "owner"}))
+	require.NoError(t, storage.DeleteByUser(ctx, "owner", []string{"zyxwvu98"}))
+	store := NewInMemoryAnalytics()
+
+	clicks := NewClickRecorder(store, storage, []byte("key"), 1000, time.Hour, nil, logger.Discard())
+	now := time.Now()
+	clicks.Record(ClickEvent{LinkID: "abcdef12", Time: now, UserAgent: testChromeUA}, "203.0.113.7")
+	clicks.Record(ClickEvent{LinkID: "abcdef12", Time: now, UserAgent: testFirefoxUA}, "203.0.113.8")
+	clicks.Record(ClickEvent{LinkID: "zyxwvu98", Time: now}, "203.0.113.7")
+	clicks.Record(ClickEvent{LinkID: "notexist", Time: now}, "203.0.113.7")
+	require.NoError(t, clicks.Shutdown(ctx))
+
+	report, err := store.OwnerReport(ctx, "owner", ReportQuery{From: now, To: now, Top: 10})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, report.TotalClicks, "переход по удаленной ссылке тоже относится к создателю")
+	assert.Equal(t, []BreakdownItem{{Name: "Chrome", Clicks: 1}, {Name: "Firefox", Clicks: 1}, {Name: unknownValue, Clicks: 1}},
+		report.Browsers)
+
+	rec, err := storage.GetRecord(ctx, "abcdef12")
+	require.NoError(t, err)
+	stats, err := store.Stats(ctx, LinkRef{ID: "abcdef12", CreatedAt: rec.CreatedAt})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, stats.TotalClicks)
+}
+
+func TestClickRecorder_DropsUnresolvedClicks(t *testing.T) {
+	ctx := context.Background()
+	created := time.Now().Add(-time.Hour)
+	storage := new(MockStorage)
+	storage.On("GetRecord", mock.Anything, "abcdef12").Return(URLRecord{ID: "abcdef12", UserID: "owner", CreatedAt: created}, nil)
+	storage.On("GetRecord", mock.Anything, "dberror1").Return(URLRecord{}, errors.New("connection refused"))
+	storage.On("GetRecord", mock.Anything, "notexist").Return(URLRecord{}, ErrNotFound)
+	appMetrics := NewMetrics(metrics.NewRegistry(), storage, logger.Discard())
+	store := NewInMemoryAnalytics()
+
+	clicks := NewClickRecorder(store, storage, []byte("key"), 1000, time.Hour, appMetrics, logger.Discard())
+	now := time.Now()
+	for _, id := range []string{"abcdef12", "dberror1", "dberror1", "notexist"} {
+		clicks.Record(ClickEvent{LinkID: id, Time: now}, "203.0.113.7")
+	}
+	require.NoError(t, clicks.Shutdown(ctx))
+
+	stats, err := store.Stats(ctx, LinkRef{ID: "abcdef12", CreatedAt: created})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, stats.TotalClicks)
+	for _, id := range []string{"dberror1", "notexist"} {
+		stats, err = store.Stats(ctx, LinkRef{ID: id})
+		require.NoError(t, err)
+		assert.Zero(t, stats.TotalClicks, "переход без найденной ссылки не сохраняется под неверным ключом")
+	}
+	assert.Equal(t, float64(3), appMetrics.clicksUnresolved.Value())
+	storage.AssertNumberOfCalls(t, "GetRecord", 3)
+}
+
+func TestShortenerService_GetLinkStats(t *testing.T) {
+	storage := NewInMemoryStorage()
+	analytics := NewInMemoryAnalytics()
+	service := NewShortenerService(storage, newTestIDGenerator(t), 3, nil, analytics, nil, logger.Discard())
+	owner := auth.WithUserID(context.Background(), "owner")
+
+	id, err := service.CreateLink(owner, "https://yandex.ru", LinkOptions{MaxClicks: 1})
+	require.NoError(t, err)
+	rec, err := storage.GetRecord(owner, id)
+	require.NoError(t, err)
+	require.NoError(t, analytics.SaveClicks(owner, []ClickEvent{{LinkID: id, LinkCreated: rec.CreatedAt, Time: time.Now(), VisitorID: "v1"}}))
+	_, err = service.GetOriginalURL(owner, id)
+	require.NoError(t, err)
+
+	stats, err := service.GetLinkStats(owner, id)
+	require.NoError(t, err, "статистика доступна и после исчерпания переходов")
+	assert.EqualValues(t, 1, stats.TotalClicks)
+
+	_, err = service.GetLinkStats(auth.WithUserID(context.Background(), "other"), id)
+	assert.ErrorIs(t, err, ErrNotFound, "чужая статистика не видна")
+	_, err = service.GetLinkStats(context.Background(), id)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = service.GetLinkStats(owner, "notexist")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestShortenerService_Reports(t *testing.T) {
+	storage := NewInMemoryStorage()
+	analytics := NewInMemoryAnalytics()
+	service := NewShortenerService(storage, newTestIDGenerator(t), 3, nil, analytics, nil, logger.Discard())
+	owner := auth.WithUserID(context.Background(), "owner")
+	now := time.Now()
+	query := ReportQuery{From: now, To: now, Top: 10}
+
+	id, err := service.CreateShortURL(owner, "https://yandex.ru")
+	require.NoError(t, err)
+	rec, err := storage.GetRecord(owner, id)
+	require.NoError(t, err)
+	require.NoError(t, analytics.SaveClicks(owner, []ClickEvent{
+		{LinkID: id, LinkCreated: rec.CreatedAt, Time: now, UserAgent: testChromeUA, OwnerID: "owner"},
+	}))
+
+	report, err := service.GetLinkReport(owner, id, query)
+	require.NoError(t, err)
+	assert.Equal(t, []BreakdownItem{{Name: "Chrome", Clicks: 1}}, report.Browsers)
+	report, err = service.GetUserReport(owner, query)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, report.TotalClicks)
+
+	other := auth.WithUserID(context.Background(), "other")
+	_, err = service.GetLinkReport(other, id, query)
+	assert.ErrorIs(t, err, ErrNotFound, "чужой отчет не виден")
+	report, err = service.GetUserReport(other, query)
+	require.NoError(t, err)
+	assert.Zero(t, report.TotalClicks)
+	_, err = service.GetUserReport(context.Background(), query)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestShortenerService_StatsOnSQLite(t *testing.T) {
+	storage := newTestSQLiteStorage(t)
+	analytics := NewInMemoryAnalytics()
+	service := NewShortenerService(storage, newTestIDGenerator(t), 3, nil, analytics, nil, logger.Discard())
+	owner := auth.WithUserID(context.Background(), "owner")
+	now := time.Now()
+	query := ReportQuery{From: now, To: now, Top: 10}
+
+	id, err := service.CreateShortURL(owner, "https://yandex.ru")
+	require.NoError(t, err)
+	clicks := NewClickRecorder(analytics, storage, []byte("key"), 1000, time.Hour, nil, logger.Discard())
+	clicks.Record(ClickEvent{LinkID: id, Time: now, UserAgent: testChromeUA}, "203.0.113.7")
+	require.NoError(t, clicks.Shutdown(owner))
+
+	stats, err := service.GetLinkStats(owner, id)
+	require.NoError(t, err, "создатель видит статистику своей ссылки")
+	assert.EqualValues(t, 1, stats.TotalClicks)
+	report, err := service.GetLinkReport(owner, id, query)
+	require.NoError(t, err)
+	assert.Equal(t, []BreakdownItem{{Name: "Chrome", Clicks: 1}}, report.Browsers)
+	report, err = service.GetUserReport(owner, query)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, report.TotalClicks, "переход относится к создателю ссылки")
+
+	_, err = service.GetLinkStats(auth.WithUserID(context.Background(), "other"), id)
+	assert.ErrorIs(t, err, ErrNotFound, "чужая статистика не видна")
+}
+
+func TestShortenerService_StatsAfterIDReuse(t *testing.T) {
+	storage := NewInMemoryStorage()
+	analytics := NewInMemoryAnalytics()
+	service := NewShortenerService(storage, newTestIDGenerator(t), 3, nil, analytics, nil, logger.Discard())
+	ctx := context.Background()
+	owner := auth.WithUserID(ctx, "owner")
+	other := auth.WithUserID(ctx, "other")
+
+	_, err := service.CreateLink(owner, "https://yandex.ru", LinkOptions{Alias: "promo", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	clicks := NewClickRecorder(analytics, storage, []byte("key"), 1000, time.Hour, nil, logger.Discard())
+	clicks.Record(ClickEvent{LinkID: "promo", Time: time.Now()}, "203.0.113.7")
+	require.NoError(t, clicks.Shutdown(ctx))
+	stats, err := service.GetLinkStats(owner, "promo")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, stats.TotalClicks)
+
+	_, err = storage.PurgeExpired(ctx, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	_, err = service.CreateLink(other, "https://ya.ru", LinkOptions{Alias: "promo"})
+	require.NoError(t, err, "алиас удаленной ссылки свободен")
+
+	stats, err = service.GetLinkStats(other, "promo")
+	require.NoError(t, err)
+	assert.Zero(t, stats.TotalClicks, "новый владелец ID не видит переходы прежней ссылки")
+	report, err := service.GetLinkReport(other, "promo", ReportQuery{From: time.Now(), To: time.Now(), Top: 10})
+	require.NoError(t, err)
+	assert.Zero(t, report.TotalClicks)
+}
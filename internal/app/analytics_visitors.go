@@ -0,0 +1,83 @@
+package app
+
+import (
+	"hash/maphash"
+	"math"
+	"math/bits"
+)
+
+const (
+	// exactVisitorsLimit - число посетителей ссылки, до которого они считаются точно;
+	// дальше счетчик переходит на оценку HyperLogLog.
+	exactVisitorsLimit = 256
+	// visitorsPrecision - число бит хеша, выбирающих регистр оценки: 2^14 регистров
+	// по байту, стандартная ошибка около 0.8%.
+	visitorsPrecision = 14
+)
+
+// visitorsSeed - затравка хеша посетителей. Оценки живут только в памяти процесса,
+// так что затравка может меняться между запусками.
+var visitorsSeed = maphash.MakeSeed()
+
+// visitorCounter считает различных посетителей ссылки в ограниченной памяти: точно,
+// пока их не больше exactVisitorsLimit, затем оценкой HyperLogLog с фиксированным
+// числом регистров.
+type visitorCounter struct {
+	exact     map[string]struct{}
+	registers []uint8 // nil, пока подсчет точный
+}
+
+func newVisitorCounter() *visitorCounter {
+	return &visitorCounter{exact: make(map[string]struct{})}
+}
+
+// add учитывает посетителя visitorID.
+func (v *visitorCounter) add(visitorID string) {
+	if v.registers == nil {
+		v.exact[visitorID] = struct{}{}
+		if len(v.exact) <= exactVisitorsLimit {
+			return
+		}
+		v.registers = make([]uint8, 1<<visitorsPrecision)
+		for id := range v.exact {
+			v.addHash(maphash.String(visitorsSeed, id))
+		}
+		v.exact = nil
+		return
+	}
+	v.addHash(maphash.String(visitorsSeed, visitorID))
+}
+
+// addHash записывает хеш посетителя в регистр, выбранный его старшими битами:
+// регистр хранит наибольший ранг первой единицы среди остальных бит.
+func (v *visitorCounter) addHash(hash uint64) {
+	index := hash >> (64 - visitorsPrecision)
+	rank := uint8(bits.LeadingZeros64(hash<<visitorsPrecision|1<<(visitorsPrecision-1))) + 1
+	if rank > v.registers[index] {
+		v.registers[index] = rank
+	}
+}
+
+// count возвращает число посетителей; estimated сообщает, что это оценка, а не точное число.
+func (v *visitorCounter) count() (n int64, estimated bool) {
+	if v.registers == nil {
+		return int64(len(v.exact)), false
+	}
+	m := float64(len(v.registers))
+	var (
+		sum   float64
+		zeros int
+	)
+	for _, r := range v.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	estimate := 0.7213 / (1 + 1.079/m) * m * m / sum
+	// На малых значениях оценка по пустым регистрам (linear counting) точнее.
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(estimate)), true
+}
@@ -1,43 +1,310 @@
 package app
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/cmpxNot29a/shurs/internal/auth"
 	"github.com/cmpxNot29a/shurs/internal/config"
+	"github.com/cmpxNot29a/shurs/internal/metrics"
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 )
 
-func App(conf *config.Config) error {
+// storageInitTimeout ограничивает время подключения к хранилищу и применения миграций.
+const storageInitTimeout = 30 * time.Second
+
+// gzipMinSize - ответы меньше этого размера не сжимаются: выигрыш не окупает накладные расходы gzip.
+const gzipMinSize = 256
+
+// App запускает HTTP-сервер и блокируется до его остановки.
+// По SIGINT/SIGTERM или отмене ctx сервер перестает принимать соединения и дожидается
+// активных запросов, затем останавливаются фоновые задачи и закрывается хранилище.
+// На все это отводится conf.ShutdownTimeout. Перед этим /readyz на conf.ReadinessDrain
+// переходит в 503, а сервер продолжает обслуживать запросы, пока балансировщик
+// не исключит его.
+func App(ctx context.Context, conf *config.Config, logger *slog.Logger) (err error) {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	idLength := conf.IDLength
 	attempts := conf.Attempts
+	shutdownTimeout := conf.ShutdownTimeout
 
-	storage := NewInMemoryStorage()
+	storage, err := newStorage(conf, logger)
+	if err != nil {
+		return fmt.Errorf("failed to init storage: %w", err)
+	}
+	defer func() {
+		if closeErr := storage.Close(); closeErr != nil {
+			logger.Error("Failed to close storage", "error", closeErr)
+			err = errors.Join(err, fmt.Errorf("failed to close storage: %w", closeErr))
+		}
+	}()
 
 	if idLength <= 0 {
-		log.Printf("WARN (App): Invalid ID Length (%d) from config, using default %d", idLength, config.DefaultIDLength)
+		logger.Warn("Invalid ID length from config, using default",
+			"id_length", idLength, "default", config.DefaultIDLength)
 		idLength = config.DefaultIDLength // Используем константу из config
 	}
 	if attempts <= 0 {
-		log.Printf("WARN (App): Invalid Attempts (%d) from config, using default %d", attempts, config.DefaultAttempts)
+		logger.Warn("Invalid attempts from config, using default",
+			"attempts", attempts, "default", config.DefaultAttempts)
 		attempts = config.DefaultAttempts // Используем константу из config
 	}
+	if shutdownTimeout <= 0 {
+		logger.Warn("Invalid shutdown timeout from config, using default",
+			"shutdown_timeout", shutdownTimeout, "default", config.DefaultShutdownTimeout)
+		shutdownTimeout = config.DefaultShutdownTimeout
+	}
 
-	var service ShortenerUseCase = NewShortenerService(storage, idLength, attempts)
-	handler := NewHandler(service, conf.BaseURL)
+	signer, err := newSigner(conf, logger)
+	if err != nil {
+		return fmt.Errorf("failed to init cookie signer: %w", err)
+	}
 
-	r := chi.NewRouter()
+	registry := metrics.NewRegistry()
+	appMetrics := NewMetrics(registry, storage, logger)
+	instrumented := InstrumentStorage(storage, appMetrics)
 
-	idValidatorMiddleware := ValidateIDMiddleware(idLength)
-	r.Post("/", ValidateURLMiddleware(http.HandlerFunc(handler.CreateShortURL)).ServeHTTP)
-	r.Get("/{id}", idValidatorMiddleware(http.HandlerFunc(handler.Redirect)).ServeHTTP)
+	idGenerator, err := newIDGenerator(conf, idLength, instrumented, logger)
+	if err != nil {
+		return fmt.Errorf("failed to init ID generator: %w", err)
+	}
 
-	log.Printf("INFO: Starting server on address %s", conf.ServerAddress)
-	err := http.ListenAndServe(conf.ServerAddress, r)
+	analytics, err := newAnalyticsStore(conf, logger)
+	if err != nil {
+		return fmt.Errorf("failed to init analytics store: %w", err)
+	}
+	defer func() {
+		if closeErr := analytics.Close(); closeErr != nil {
+			logger.Error("Failed to close analytics store", "error", closeErr)
+			err = errors.Join(err, fmt.Errorf("failed to close analytics store: %w", closeErr))
+		}
+	}()
+	analyticsKey, err := newAnalyticsKey(conf, logger)
 	if err != nil {
-		return fmt.Errorf("server failed to start: %w", err)
+		return fmt.Errorf("failed to init analytics key: %w", err)
+	}
+
+	deleter := NewURLDeleter(instrumented, deleterBatchSize, deleterFlushInterval, logger)
+	reaper := newExpiryReaper(conf, instrumented, logger)
+	var service ShortenerUseCase = NewShortenerService(instrumented, idGenerator, attempts, deleter, analytics, appMetrics, logger)
+	service = InstrumentService(service, appMetrics)
+	clicks := NewClickRecorder(analytics, instrumented, analyticsKey, clicksBatchSize, clicksFlushInterval, appMetrics, logger)
+	handler := NewHandler(service, clicks, conf.BaseURL, logger)
+	health := NewHealthHandler(instrumented, logger)
+
+	server := &http.Server{
+		Addr:    conf.ServerAddress,
+		Handler: newRouter(handler, health, registry, appMetrics, signer, idGenerator.Format(), logger),
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		logger.Info("Starting server", "address", conf.ServerAddress)
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		// Сервер не запустился: запросов не было, но принятые заявки все равно сбрасываются.
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return errors.Join(fmt.Errorf("server failed: %w", err), stopWorkers(shutdownCtx, deleter, reaper, clicks))
+	case <-ctx.Done():
+	}
+
+	health.SetShuttingDown()
+	if conf.ReadinessDrain > 0 {
+		logger.Info("Waiting for load balancers to stop routing requests", "delay", conf.ReadinessDrain)
+		time.Sleep(conf.ReadinessDrain)
+	}
+	logger.Info("Shutting down", "timeout", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	var shutdownErr error
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Failed to drain in-flight requests", "error", err)
+		shutdownErr = fmt.Errorf("failed to shut down server: %w", err)
+	}
+	shutdownErr = errors.Join(shutdownErr, stopWorkers(shutdownCtx, deleter, reaper, clicks))
+	if shutdownErr == nil {
+		logger.Info("Server stopped")
+	}
+	return shutdownErr
+}
+
+// newRouter собирает маршруты и middleware сервиса.
+// Пробы и метрики не проходят через сжатие и аутентификацию: оркестратору и
+// Prometheus не нужны cookie.
+func newRouter(handler *Handler, health *HealthHandler, registry *metrics.Registry, appMetrics *Metrics,
+	signer *auth.Signer, idFormat IDFormat, logger *slog.Logger) http.Handler {
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(RequestLoggerMiddleware(logger))
+	r.Use(MetricsMiddleware(appMetrics))
+
+	r.Get("/ping", health.Ping)
+	r.Get("/healthz", health.Healthz)
+	r.Get("/readyz", health.Readyz)
+	r.Get("/metrics", registry.Handler().ServeHTTP)
+
+	r.Group(func(r chi.Router) {
+		r.Use(GzipMiddleware(gzipMinSize, logger))
+		r.Use(AuthMiddleware(signer, logger))
+
+		idValidatorMiddleware := ValidateIDMiddleware(idFormat, logger)
+		urlValidatorMiddleware := ValidateURLMiddleware(logger)
+		r.Post("/", urlValidatorMiddleware(http.HandlerFunc(handler.CreateShortURL)).ServeHTTP)
+		r.Post("/api/shorten", handler.ShortenJSON)
+		r.Post("/api/shorten/batch", handler.ShortenBatch)
+		r.Get("/api/user/urls", handler.GetUserURLs)
+		r.Delete("/api/user/urls", handler.DeleteUserURLs)
+		r.Get("/api/user/report", handler.GetUserReport)
+		r.Get("/api/links/{id}", idValidatorMiddleware(http.HandlerFunc(handler.ResolveJSON)).ServeHTTP)
+		r.Get("/api/links/{id}/stats", idValidatorMiddleware(http.HandlerFunc(handler.GetLinkStats)).ServeHTTP)
+		r.Get("/api/links/{id}/report", idValidatorMiddleware(http.HandlerFunc(handler.GetLinkReport)).ServeHTTP)
+		r.Get("/{id}", idValidatorMiddleware(http.HandlerFunc(handler.Redirect)).ServeHTTP)
+		r.Post("/{id}", idValidatorMiddleware(http.HandlerFunc(handler.Unlock)).ServeHTTP)
+	})
+	return r
+}
+
+// stopWorkers останавливает фоновые задачи, дожидаясь сброса их очередей.
+// reaper может быть nil, если удаление истекших ссылок выключено.
+func stopWorkers(ctx context.Context, deleter *URLDeleter, reaper *ExpiryReaper, clicks *ClickRecorder) error {
+	var err error
+	if reaper != nil {
+		if reapErr := reaper.Shutdown(ctx); reapErr != nil {
+			err = fmt.Errorf("failed to stop expiry reaper: %w", reapErr)
+		}
+	}
+	if deleteErr := deleter.Shutdown(ctx); deleteErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to flush deletion queue: %w", deleteErr))
+	}
+	if clicksErr := clicks.Shutdown(ctx); clicksErr != nil {
+		err = errors.Join(err, fmt.Errorf("failed to flush click queue: %w", clicksErr))
+	}
+	return err
+}
+
+// newExpiryReaper запускает удаление истекших ссылок, если оно включено и хранилище его поддерживает.
+func newExpiryReaper(conf *config.Config, storage Storage, logger *slog.Logger) *ExpiryReaper {
+	if conf.ReapInterval <= 0 {
+		logger.Info("Expired links purging is disabled")
+		return nil
+	}
+	purger, ok := storageCapability[ExpiredPurger](storage)
+	if !ok {
+		logger.Warn("Storage does not support purging expired links")
+		return nil
+	}
+	return NewExpiryReaper(purger, conf.ReapInterval, logger)
+}
+
+// newIDGenerator выбирает генератор коротких ID согласно конфигурации.
+func newIDGenerator(conf *config.Config, idLength int, storage Storage, logger *slog.Logger) (IDGenerator, error) {
+	alphabet := conf.IDAlphabet
+	if alphabet == "" {
+		alphabet = config.DefaultIDAlphabet
+	}
+
+	switch conf.IDStrategy {
+	case config.IDStrategyRandom, "":
+	case config.IDStrategySequential:
+		allocator, ok := storageCapability[SequenceAllocator](storage)
+		if !ok {
+			return nil, fmt.Errorf("storage does not support sequential IDs")
+		}
+		if conf.IDEscalateAfter > 0 {
+			logger.Warn("ID length escalation is not used with sequential IDs")
+		}
+		logger.Info("Using sequential ID generator", "id_length", idLength, "block_size", conf.IDBlockSize)
+		return NewSequentialIDGenerator(allocator, alphabet, idLength, int64(conf.IDBlockSize), conf.IDKey)
+	case config.IDStrategyHash:
+		if conf.IDEscalateAfter > 0 {
+			logger.Warn("ID length escalation is not used with hash IDs")
+		}
+		logger.Info("Using hash ID generator", "id_length", idLength)
+		return NewHashIDGenerator(alphabet, idLength, conf.IDKey)
+	default:
+		return nil, fmt.Errorf("unknown ID strategy %q", conf.IDStrategy)
+	}
+
+	if conf.IDEscalateAfter > 0 {
+		logger.Info("Using random ID generator with length escalation",
+			"id_length", idLength, "escalate_after", conf.IDEscalateAfter)
+		return NewEscalatingIDGenerator(alphabet, idLength, conf.IDEscalateAfter, logger)
+	}
+	return NewRandomIDGenerator(alphabet, idLength)
+}
+
+// newSigner создает подписчик cookie из ключей конфигурации.
+// Без ключа генерируется случайный: cookie перестанут приниматься после перезапуска.
+func newSigner(conf *config.Config, logger *slog.Logger) (*auth.Signer, error) {
+	key := conf.AuthKey
+	if key == "" {
+		logger.Warn("Auth key is not configured, using a random key; user cookies will not survive restart")
+		randomKey, err := auth.NewRandomKey()
+		if err != nil {
+			return nil, err
+		}
+		key = randomKey
+	}
+	return auth.NewSigner(key, conf.AuthPrevKey)
+}
+
+// newAnalyticsStore выбирает реализацию AnalyticsStore согласно конфигурации.
+func newAnalyticsStore(conf *config.Config, logger *slog.Logger) (AnalyticsStore, error) {
+	if conf.AnalyticsFilePath != "" {
+		logger.Info("Using file analytics store", "path", conf.AnalyticsFilePath)
+		return NewFileAnalytics(conf.AnalyticsFilePath, logger)
+	}
+	logger.Info("Using in-memory analytics store")
+	return NewInMemoryAnalytics(), nil
+}
+
+// newAnalyticsKey возвращает ключ идентификаторов посетителей из конфигурации.
+// Без ключа генерируется случайный: после перезапуска посетители будут считаться заново.
+func newAnalyticsKey(conf *config.Config, logger *slog.Logger) ([]byte, error) {
+	if conf.AnalyticsKey != "" {
+		return []byte(conf.AnalyticsKey), nil
+	}
+	logger.Warn("Analytics key is not configured, using a random key; unique visitors will be counted anew after restart")
+	key := make([]byte, sha256.Size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// newStorage выбирает реализацию Storage согласно конфигурации.
+func newStorage(conf *config.Config, logger *slog.Logger) (Storage, error) {
+	if conf.DatabaseDSN != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), storageInitTimeout)
+		defer cancel()
+		if IsSQLiteDSN(conf.DatabaseDSN) {
+			logger.Info("Using SQLite storage")
+			return NewSQLiteStorage(ctx, conf.DatabaseDSN, logger)
+		}
+		logger.Info("Using PostgreSQL storage")
+		return NewPostgresStorage(ctx, conf.DatabaseDSN, logger)
+	}
+	if conf.FileStoragePath != "" {
+		logger.Info("Using file storage", "path", conf.FileStoragePath)
+		return NewFileStorage(conf.FileStoragePath, logger)
 	}
-	return nil
+	logger.Info("Using in-memory storage")
+	return NewInMemoryStorage(), nil
 }
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/cmpxNot29a/shurs/internal/config"
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// freeAddress возвращает адрес свободного локального порта.
+func freeAddress(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := l.Addr().String()
+	require.NoError(t, l.Close())
+	return addr
+}
+
+// Приложение запускается на случайном порту, получает SIGTERM и должно завершиться
+// без ошибок, успев сбросить очередь удаления и закрыть файловое хранилище. До остановки
+// сервера /readyz на время ReadinessDrain отвечает 503.
+func TestApp_GracefulShutdownOnSignal(t *testing.T) {
+	addr := freeAddress(t)
+	path := filepath.Join(t.TempDir(), "storage.json")
+	conf := &config.Config{
+		ServerAddress:   addr,
+		BaseURL:         "http://" + addr,
+		IDLength:        config.DefaultIDLength,
+		Attempts:        config.DefaultAttempts,
+		FileStoragePath: path,
+		ShutdownTimeout: 5 * time.Second,
+		ReadinessDrain:  time.Second,
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- App(context.Background(), conf, logger.Discard())
+	}()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	client := &http.Client{Jar: jar}
+	baseURL := "http://" + addr
+
+	var shortURL string
+	require.Eventually(t, func() bool {
+		resp, err := client.Post(baseURL+"/", "text/plain", strings.NewReader("https://yandex.ru"))
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		shortURL = string(body)
+		return resp.StatusCode == http.StatusCreated
+	}, 5*time.Second, 20*time.Millisecond, "сервер не запустился")
+	id := shortURL[strings.LastIndex(shortURL, "/")+1:]
+
+	ready, err := client.Get(baseURL + "/readyz")
+	require.NoError(t, err)
+	ready.Body.Close()
+	assert.Equal(t, http.StatusOK, ready.StatusCode)
+
+	metricsResp, err := client.Get(baseURL + "/metrics")
+	require.NoError(t, err)
+	metricsBody, err := io.ReadAll(metricsResp.Body)
+	metricsResp.Body.Close()
+	require.NoError(t, err)
+	assert.Contains(t, string(metricsBody), `shortener_http_requests_total{method="POST",route="/",status="201"} 1`)
+
+	req, err := http.NewRequest(http.MethodDelete, baseURL+"/api/user/urls", strings.NewReader(`["`+id+`"]`))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	// Соединение, открытое транспортом клиента про запас и не получившее ни одного
+	// запроса, server.Shutdown считает активным еще 5 секунд; закрываем такие заранее.
+	client.CloseIdleConnections()
+
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, process.Signal(syscall.SIGTERM))
+
+	require.Eventually(t, func() bool {
+		resp, err := client.Get(baseURL + "/readyz")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusServiceUnavailable
+	}, time.Second, 20*time.Millisecond, "во время паузы сервер отвечает, но уже не готов")
+	client.CloseIdleConnections()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("приложение не остановилось после SIGTERM")
+	}
+
+	_, err = client.Get(baseURL + "/" + id)
+	assert.Error(t, err, "сервер должен перестать принимать соединения")
+
+	s, err := NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+	defer s.Close()
+	_, err = s.GetByID(context.Background(), id)
+	assert.ErrorIs(t, err, ErrDeleted, "очередь удаления должна быть сброшена при остановке")
+}
@@ -0,0 +1,129 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// deleterQueueSize - емкость очереди заявок на удаление.
+	deleterQueueSize = 1024
+	// deleterBatchSize - число накопленных ID, после которого пачка сбрасывается в хранилище.
+	deleterBatchSize = 100
+	// deleterFlushInterval - наибольшее время ожидания ID в очереди.
+	deleterFlushInterval = time.Second
+)
+
+// ErrShuttingDown - сервис останавливается и больше не принимает заявки.
+var ErrShuttingDown = errors.New("service is shutting down")
+
+// deleteTask - заявка пользователя на удаление ссылок.
+type deleteTask struct {
+	userID string
+	ids    []string
+}
+
+// URLDeleter удаляет ссылки в фоне. Заявки копятся в очереди, группируются
+// по владельцу и сбрасываются в хранилище пачками: по достижении batchSize ID
+// или раз в flushInterval. Shutdown дожидается сброса всех принятых заявок.
+type URLDeleter struct {
+	storage       Storage
+	tasks         chan deleteTask
+	batchSize     int
+	flushInterval time.Duration
+	mu            sync.RWMutex // защищает closed от гонки с закрытием tasks
+	closed        bool
+	done          chan struct{}
+	logger        *slog.Logger
+}
+
+// NewURLDeleter создает удалятель и запускает его рабочую горутину.
+func NewURLDeleter(storage Storage, batchSize int, flushInterval time.Duration, logger *slog.Logger) *URLDeleter {
+	d := &URLDeleter{
+		storage:       storage,
+		tasks:         make(chan deleteTask, deleterQueueSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		done:          make(chan struct{}),
+		logger:        logger.With("component", "deleter"),
+	}
+	go d.run()
+	return d
+}
+
+// Enqueue ставит ID пользователя в очередь на удаление.
+// Возвращает ErrShuttingDown после начала остановки.
+func (d *URLDeleter) Enqueue(ctx context.Context, userID string, ids []string) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return ErrShuttingDown
+	}
+	select {
+	case d.tasks <- deleteTask{userID: userID, ids: ids}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown перестает принимать заявки и ждет, пока очередь будет сброшена в хранилище.
+func (d *URLDeleter) Shutdown(ctx context.Context) error {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.tasks)
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-d.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (d *URLDeleter) run() {
+	defer close(d.done)
+
+	ticker := time.NewTicker(d.flushInterval)
+	defer ticker.Stop()
+
+	pending := make(map[string][]string)
+	count := 0
+	for {
+		select {
+		case task, ok := <-d.tasks:
+			if !ok {
+				d.flush(pending)
+				return
+			}
+			pending[task.userID] = append(pending[task.userID], task.ids...)
+			count += len(task.ids)
+			if count >= d.batchSize {
+				d.flush(pending)
+				count = 0
+			}
+		case <-ticker.C:
+			d.flush(pending)
+			count = 0
+		}
+	}
+}
+
+// flush удаляет накопленные ID одним вызовом хранилища на владельца и очищает pending.
+func (d *URLDeleter) flush(pending map[string][]string) {
+	for userID, ids := range pending {
+		if err := d.storage.DeleteByUser(context.Background(), userID, ids); err != nil {
+			d.logger.Error("Failed to delete URLs", "user_id", userID, "count", len(ids), "error", err)
+		} else {
+			d.logger.Debug("URLs deleted", "user_id", userID, "count", len(ids))
+		}
+		delete(pending, userID)
+	}
+}
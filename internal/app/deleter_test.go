@@ -0,0 +1,52 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLDeleter_FlushesOnShutdown(t *testing.T) {
+	ctx := context.Background()
+	storage := NewInMemoryStorage()
+	require.NoError(t, storage.Save(ctx, URLRecord{ID: "abcdef12", OriginalURL: "https://yandex.ru", UserID: "owner"}))
+	require.NoError(t, storage.Save(ctx, URLRecord{ID: "ABCDEF34", OriginalURL: "https://google.com", UserID: "owner"}))
+	require.NoError(t, storage.Save(ctx, URLRecord{ID: "zyxwvu98", OriginalURL: "https://ya.ru", UserID: "other"}))
+
+	// Пачка и таймер заведомо не срабатывают: удалить ссылки должен только Shutdown.
+	deleter := NewURLDeleter(storage, 1000, time.Hour, logger.Discard())
+	require.NoError(t, deleter.Enqueue(ctx, "owner", []string{"abcdef12"}))
+	require.NoError(t, deleter.Enqueue(ctx, "owner", []string{"ABCDEF34", "zyxwvu98"}))
+	require.NoError(t, deleter.Shutdown(ctx))
+
+	_, err := storage.GetByID(ctx, "abcdef12")
+	assert.ErrorIs(t, err, ErrDeleted)
+	_, err = storage.GetByID(ctx, "ABCDEF34")
+	assert.ErrorIs(t, err, ErrDeleted)
+
+	url, err := storage.GetByID(ctx, "zyxwvu98")
+	require.NoError(t, err, "чужая ссылка не должна удаляться")
+	assert.Equal(t, "https://ya.ru", url)
+
+	assert.ErrorIs(t, deleter.Enqueue(ctx, "owner", []string{"abcdef12"}), ErrShuttingDown)
+}
+
+func TestURLDeleter_FlushesFullBatch(t *testing.T) {
+	ctx := context.Background()
+	storage := NewInMemoryStorage()
+	require.NoError(t, storage.Save(ctx, URLRecord{ID: "abcdef12", OriginalURL: "https://yandex.ru", UserID: "owner"}))
+
+	deleter := NewURLDeleter(storage, 1, time.Hour, logger.Discard())
+	defer deleter.Shutdown(ctx)
+	require.NoError(t, deleter.Enqueue(ctx, "owner", []string{"abcdef12"}))
+
+	assert.Eventually(t, func() bool {
+		_, err := storage.GetByID(ctx, "abcdef12")
+		return errors.Is(err, ErrDeleted)
+	}, time.Second, 10*time.Millisecond)
+}
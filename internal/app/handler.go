@@ -4,8 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -13,55 +15,118 @@ import (
 // Handler обрабатывает HTTP запросы, делегируя логику сервису.
 type Handler struct {
 	service ShortenerUseCase
+	clicks  *ClickRecorder // nil - переходы не записываются
 	baseURL string
+	logger  *slog.Logger
 }
 
 // NewHandler создает новый экземпляр Handler.
-func NewHandler(service ShortenerUseCase, baseURL string) *Handler {
+func NewHandler(service ShortenerUseCase, clicks *ClickRecorder, baseURL string, logger *slog.Logger) *Handler {
 	return &Handler{
 		service: service,
+		clicks:  clicks,
 		baseURL: baseURL,
+		logger:  logger.With("component", "handler"),
 	}
 }
 
 // CreateShortURL обрабатывает POST /
+// Для уже сокращенного URL возвращает существующую ссылку со статусом 409.
 func (h *Handler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	bodyBytes, err := io.ReadAll(r.Body)
 	if err != nil {
-		log.Printf("ERROR: Handler: Failed to read request body: %v", err)
+		h.logger.Error("Failed to read request body", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 	originalURL := string(bodyBytes)
 
+	status := http.StatusCreated
 	shortID, err := h.service.CreateShortURL(r.Context(), originalURL)
-	if err != nil {
-		log.Printf("ERROR: Handler: Service failed to create short URL: %v", err)
+	if errors.Is(err, ErrURLExists) {
+		h.logger.Info("URL already shortened", "url", originalURL, "id", shortID)
+		status = http.StatusConflict
+	} else if err != nil {
+		h.logger.Error("Service failed to create short URL", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
 
 	shortURL := fmt.Sprintf("%s/%s", h.baseURL, shortID)
 	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-	w.WriteHeader(http.StatusCreated)
+	w.WriteHeader(status)
 	w.Write([]byte(shortURL))
 }
 
 // Redirect обрабатывает GET /{id}
+// Для ссылки с паролем вместо перенаправления показывает форму ввода пароля (см. Unlock).
 func (h *Handler) Redirect(w http.ResponseWriter, r *http.Request) {
 	shortID := chi.URLParam(r, "id")
 
 	originalURL, err := h.service.GetOriginalURL(r.Context(), shortID)
+	if errors.Is(err, ErrPasswordRequired) {
+		h.renderPasswordPrompt(w, http.StatusOK, "")
+		return
+	}
 	if err != nil {
-		if errors.Is(err, ErrNotFound) {
-			log.Printf("WARN: Handler: ID not found: %s", shortID)
-			http.Error(w, "URL not found", http.StatusNotFound)
-		} else {
-			log.Printf("ERROR: Handler: Service failed to get original URL: %v", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-		}
+		status, message := h.linkError(shortID, err)
+		http.Error(w, message, status)
 		return
 	}
+	h.recordClick(r, shortID)
 	http.Redirect(w, r, originalURL, http.StatusTemporaryRedirect)
 }
+
+// recordClick ставит в очередь событие успешного перехода по ссылке.
+func (h *Handler) recordClick(r *http.Request, shortID string) {
+	if h.clicks == nil {
+		return
+	}
+	h.clicks.Record(ClickEvent{
+		LinkID:    shortID,
+		Time:      time.Now().UTC(),
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+	}, clientIP(r))
+}
+
+// clientIP возвращает IP-адрес клиента из адреса соединения. Заголовкам вроде
+// X-Forwarded-For не доверяем: их может подставить сам клиент.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// linkError логирует ошибку перехода по ссылке и возвращает статус и текст ответа на нее.
+func (h *Handler) linkError(shortID string, err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		h.logger.Warn("ID not found", "id", shortID)
+		return http.StatusNotFound, "URL not found"
+	case errors.Is(err, ErrDeleted):
+		h.logger.Info("ID deleted", "id", shortID)
+		return http.StatusGone, "URL deleted"
+	case errors.Is(err, ErrExpired):
+		h.logger.Info("ID expired", "id", shortID)
+		return http.StatusGone, "URL expired"
+	case errors.Is(err, ErrClicksExhausted):
+		h.logger.Info("ID click limit reached", "id", shortID)
+		return http.StatusGone, "URL click limit reached"
+	case errors.Is(err, ErrPasswordRequired):
+		h.logger.Info("ID requires password", "id", shortID)
+		return http.StatusUnauthorized, "Password required"
+	case errors.Is(err, ErrWrongPassword):
+		h.logger.Info("Wrong password for ID", "id", shortID)
+		return http.StatusForbidden, "Wrong password"
+	case errors.Is(err, ErrTooManyAttempts):
+		h.logger.Warn("Too many password attempts for ID", "id", shortID)
+		return http.StatusTooManyRequests, "Too many password attempts, try again later"
+	default:
+		h.logger.Error("Service failed to get original URL", "id", shortID, "error", err)
+		return http.StatusInternalServerError, "Internal server error"
+	}
+}
@@ -0,0 +1,377 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cmpxNot29a/shurs/internal/auth"
+	"github.com/cmpxNot29a/shurs/internal/helper"
+	"github.com/go-chi/chi/v5"
+)
+
+// ShortenRequest - тело запроса POST /api/shorten.
+type ShortenRequest struct {
+	URL       string     `json:"url"`
+	Alias     string     `json:"alias,omitempty"`      // необязательный пользовательский ID
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // момент истечения в RFC 3339
+	TTL       string     `json:"ttl,omitempty"`        // срок жизни в формате time.ParseDuration, например "72h"
+	MaxClicks int64      `json:"max_clicks,omitempty"` // сколько раз можно перейти по ссылке
+	Password  string     `json:"password,omitempty"`   // пароль для перехода по ссылке
+}
+
+// linkOptions собирает параметры ссылки из запроса. Срок действия задается либо
+// expires_at, либо ttl и должен заканчиваться позже now.
+func (req ShortenRequest) linkOptions(now time.Time) (LinkOptions, error) {
+	if req.MaxClicks < 0 {
+		return LinkOptions{}, errors.New("max_clicks must be positive")
+	}
+	opts := LinkOptions{Alias: req.Alias, MaxClicks: req.MaxClicks, Password: req.Password}
+	switch {
+	case req.ExpiresAt != nil && req.TTL != "":
+		return LinkOptions{}, errors.New("only one of expires_at and ttl may be set")
+	case req.ExpiresAt != nil:
+		opts.ExpiresAt = *req.ExpiresAt
+	case req.TTL != "":
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil {
+			return LinkOptions{}, fmt.Errorf("invalid ttl: %w", err)
+		}
+		opts.ExpiresAt = now.Add(ttl)
+	default:
+		return opts, nil
+	}
+	if !opts.ExpiresAt.After(now) {
+		return LinkOptions{}, errors.New("expiration must be in the future")
+	}
+	return opts, nil
+}
+
+// ShortenResponse - тело ответа POST /api/shorten.
+type ShortenResponse struct {
+	Result string `json:"result"`
+}
+
+// BatchRequestItem - элемент тела запроса POST /api/shorten/batch.
+type BatchRequestItem struct {
+	CorrelationID string `json:"correlation_id"`
+	OriginalURL   string `json:"original_url"`
+}
+
+// BatchResponseItem - элемент тела ответа POST /api/shorten/batch.
+type BatchResponseItem struct {
+	CorrelationID string `json:"correlation_id"`
+	ShortURL      string `json:"short_url"`
+}
+
+// UserURLItem - элемент ответа GET /api/user/urls.
+type UserURLItem struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+}
+
+// LinkStatsResponse - тело ответа GET /api/links/{id}/stats.
+type LinkStatsResponse struct {
+	TotalClicks             int64             `json:"total_clicks"`
+	UniqueVisitors          int64             `json:"unique_visitors"`
+	UniqueVisitorsEstimated bool              `json:"unique_visitors_estimated,omitempty"` // unique_visitors - приближенная оценка
+	Daily                   []DailyClicksItem `json:"daily"`
+}
+
+// DailyClicksItem - элемент посуточной статистики в LinkStatsResponse.
+type DailyClicksItem struct {
+	Date   string `json:"date"`
+	Clicks int64  `json:"clicks"`
+}
+
+// ErrorResponse - тело ответа с ошибкой для JSON API.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeJSON сериализует value в ответ с указанным статусом.
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, value any) {
+	writeJSONResponse(w, status, value, h.logger)
+}
+
+// writeJSONResponse сериализует value в ответ с указанным статусом; ошибка кодирования логируется.
+func writeJSONResponse(w http.ResponseWriter, status int, value any, logger *slog.Logger) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logger.Error("Failed to encode JSON response", "error", err)
+	}
+}
+
+// writeJSONError отправляет ошибку JSON API в виде {"error": "..."}.
+func (h *Handler) writeJSONError(w http.ResponseWriter, status int, message string) {
+	h.writeJSON(w, status, ErrorResponse{Error: message})
+}
+
+// ShortenJSON обрабатывает POST /api/shorten
+// Для уже сокращенного URL возвращает существующую ссылку со статусом 409.
+// С полем alias ссылка создается под этим алиасом: недопустимый алиас - 400,
+// занятый - 409 с ошибкой вместо ссылки. Поля expires_at или ttl ограничивают
+// срок действия ссылки, поле max_clicks - число переходов; после этого
+// переход по ссылке отвечает 410. С полем password переход по ссылке требует
+// пароль (см. Handler.Unlock); недопустимый пароль - 400. Если URL уже сокращен,
+// запрос с этими ограничениями получает 409 с ошибкой вместо ссылки: существующая
+// ссылка их не соблюдает.
+func (h *Handler) ShortenJSON(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var req ShortenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.logger.Warn("Failed to decode request body", "error", err)
+		h.writeJSONError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	if !helper.IsValidURL(req.URL) {
+		h.logger.Warn("Invalid URL received", "url", req.URL)
+		h.writeJSONError(w, http.StatusBadRequest, "Invalid URL format")
+		return
+	}
+
+	opts, err := req.linkOptions(time.Now())
+	if err != nil {
+		h.logger.Warn("Invalid link options received", "error", err)
+		h.writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	status := http.StatusCreated
+	var shortID string
+	if opts == (LinkOptions{}) {
+		shortID, err = h.service.CreateShortURL(r.Context(), req.URL)
+	} else {
+		shortID, err = h.service.CreateLink(r.Context(), req.URL, opts)
+	}
+	if errors.Is(err, ErrInvalidAlias) {
+		h.logger.Warn("Invalid alias received", "alias", req.Alias, "error", err)
+		h.writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, ErrInvalidPassword) {
+		h.logger.Warn("Invalid password received", "error", err)
+		h.writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if errors.Is(err, ErrConflict) {
+		h.logger.Info("Alias already taken", "alias", req.Alias)
+		h.writeJSONError(w, http.StatusConflict, "Alias is already taken")
+		return
+	} else if errors.Is(err, ErrRestrictedURLExists) {
+		h.logger.Info("URL already shortened, restrictions rejected", "url", req.URL)
+		h.writeJSONError(w, http.StatusConflict, "URL is already shortened without the requested restrictions")
+		return
+	} else if errors.Is(err, ErrURLExists) {
+		h.logger.Info("URL already shortened", "url", req.URL, "id", shortID)
+		status = http.StatusConflict
+	} else if err != nil {
+		h.logger.Error("Service failed to create short URL", "error", err)
+		h.writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	h.writeJSON(w, status, ShortenResponse{
+		Result: fmt.Sprintf("%s/%s", h.baseURL, shortID),
+	})
+}
+
+// ShortenBatch обрабатывает POST /api/shorten/batch. Пачка больше maxBatchURLs
+// отклоняется с 400.
+func (h *Handler) ShortenBatch(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+
+	var items []BatchRequestItem
+	if err := json.NewDecoder(r.Body).Decode(&items); err != nil {
+		h.logger.Warn("Failed to decode batch body", "error", err)
+		h.writeJSONError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if len(items) == 0 {
+		h.writeJSONError(w, http.StatusBadRequest, "Empty batch")
+		return
+	}
+	if len(items) > maxBatchURLs {
+		h.writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Batch is too large: at most %d URLs allowed", maxBatchURLs))
+		return
+	}
+
+	originalURLs := make([]string, len(items))
+	for i, item := range items {
+		if !helper.IsValidURL(item.OriginalURL) {
+			h.logger.Warn("Invalid URL in batch", "correlation_id", item.CorrelationID, "url", item.OriginalURL)
+			h.writeJSONError(w, http.StatusBadRequest,
+				fmt.Sprintf("Invalid URL format for correlation_id %q", item.CorrelationID))
+			return
+		}
+		originalURLs[i] = item.OriginalURL
+	}
+
+	shortIDs, err := h.service.CreateShortURLBatch(r.Context(), originalURLs)
+	if err != nil {
+		h.logger.Error("Service failed to create batch", "size", len(originalURLs), "error", err)
+		h.writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	resp := make([]BatchResponseItem, len(items))
+	for i, item := range items {
+		resp[i] = BatchResponseItem{
+			CorrelationID: item.CorrelationID,
+			ShortURL:      fmt.Sprintf("%s/%s", h.baseURL, shortIDs[i]),
+		}
+	}
+	h.writeJSON(w, http.StatusCreated, resp)
+}
+
+const (
+	// defaultUserURLsLimit - размер страницы GET /api/user/urls, если limit не указан.
+	defaultUserURLsLimit = 100
+	// maxUserURLsLimit - наибольший допустимый размер страницы.
+	maxUserURLsLimit = 1000
+	// maxDeleteIDs - наибольшее число ID в одном запросе DELETE /api/user/urls.
+	maxDeleteIDs = 1000
+	// maxBatchURLs - наибольшее число URL в одном запросе POST /api/shorten/batch.
+	maxBatchURLs = 1000
+	// NextCursorHeader - заголовок с курсором следующей страницы.
+	NextCursorHeader = "X-Next-Cursor"
+)
+
+// GetUserURLs обрабатывает GET /api/user/urls?limit=N&cursor=C
+// Возвращает 401 без действительной cookie, 204 если ссылок нет.
+// Курсор следующей страницы передается в заголовке X-Next-Cursor.
+func (h *Handler) GetUserURLs(w http.ResponseWriter, r *http.Request) {
+	if !auth.IsAuthenticated(r.Context()) {
+		h.writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	limit := defaultUserURLsLimit
+	if rawLimit := r.URL.Query().Get("limit"); rawLimit != "" {
+		parsed, err := strconv.Atoi(rawLimit)
+		if err != nil || parsed <= 0 || parsed > maxUserURLsLimit {
+			h.writeJSONError(w, http.StatusBadRequest,
+				fmt.Sprintf("limit must be between 1 and %d", maxUserURLsLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	page, err := h.service.GetUserURLs(r.Context(), r.URL.Query().Get("cursor"), limit)
+	if errors.Is(err, ErrInvalidCursor) {
+		h.writeJSONError(w, http.StatusBadRequest, "Invalid cursor")
+		return
+	}
+	if err != nil {
+		h.logger.Error("Service failed to get user URLs", "error", err)
+		h.writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	if page.NextCursor != "" {
+		w.Header().Set(NextCursorHeader, page.NextCursor)
+	}
+	if len(page.Records) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	resp := make([]UserURLItem, len(page.Records))
+	for i, rec := range page.Records {
+		resp[i] = UserURLItem{
+			ShortURL:    fmt.Sprintf("%s/%s", h.baseURL, rec.ID),
+			OriginalURL: rec.OriginalURL,
+		}
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// DeleteUserURLs обрабатывает DELETE /api/user/urls: принимает JSON-массив коротких ID
+// и ставит их в очередь на удаление. Удаляются только ссылки, созданные вызывающим.
+func (h *Handler) DeleteUserURLs(w http.ResponseWriter, r *http.Request) {
+	if !auth.IsAuthenticated(r.Context()) {
+		h.writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	var ids []string
+	if err := json.NewDecoder(r.Body).Decode(&ids); err != nil {
+		h.logger.Warn("Failed to decode JSON body", "error", err)
+		h.writeJSONError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if len(ids) == 0 || len(ids) > maxDeleteIDs {
+		h.writeJSONError(w, http.StatusBadRequest,
+			fmt.Sprintf("ids count must be between 1 and %d", maxDeleteIDs))
+		return
+	}
+	for _, id := range ids {
+		if !isValidShortID(id) {
+			h.writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid ID format %q", id))
+			return
+		}
+	}
+
+	err := h.service.DeleteUserURLs(r.Context(), ids)
+	if errors.Is(err, ErrShuttingDown) {
+		h.writeJSONError(w, http.StatusServiceUnavailable, "Service is shutting down")
+		return
+	}
+	if err != nil {
+		h.logger.Error("Service failed to delete user URLs", "error", err)
+		h.writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// GetLinkStats обрабатывает GET /api/links/{id}/stats: число переходов, уникальных
+// посетителей и переходы по дням UTC. Возвращает 401 без действительной cookie
+// и 404, если ссылки нет или ее создал другой пользователь.
+func (h *Handler) GetLinkStats(w http.ResponseWriter, r *http.Request) {
+	if !auth.IsAuthenticated(r.Context()) {
+		h.writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	shortID := chi.URLParam(r, "id")
+
+	stats, err := h.service.GetLinkStats(r.Context(), shortID)
+	if errors.Is(err, ErrNotFound) {
+		h.writeJSONError(w, http.StatusNotFound, "URL not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("Service failed to get link stats", "id", shortID, "error", err)
+		h.writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	resp := LinkStatsResponse{
+		TotalClicks:             stats.TotalClicks,
+		UniqueVisitors:          stats.UniqueVisitors,
+		UniqueVisitorsEstimated: stats.UniqueVisitorsEstimated,
+		Daily:                   make([]DailyClicksItem, len(stats.Daily)),
+	}
+	for i, day := range stats.Daily {
+		resp.Daily[i] = DailyClicksItem{Date: day.Date, Clicks: day.Clicks}
+	}
+	h.writeJSON(w, http.StatusOK, resp)
+}
+
+// isValidShortID сообщает, может ли строка быть коротким ID какого-либо генератора.
+func isValidShortID(id string) bool {
+	if id == "" || len(id) > MaxIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if !isUnreservedURLChar(id[i]) {
+			return false
+		}
+	}
+	return true
+}
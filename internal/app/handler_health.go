@@ -0,0 +1,90 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// pingTimeout ограничивает проверку связи с хранилищем в пробах.
+const pingTimeout = time.Second
+
+// Статусы в ответах проб.
+const (
+	healthStatusOK           = "ok"
+	healthStatusError        = "error"
+	healthStatusShuttingDown = "shutting_down"
+)
+
+// HealthResponse - тело ответа проб /ping, /healthz и /readyz. Текст ошибки хранилища
+// в ответ не попадает, только в лог: пробы доступны без аутентификации.
+type HealthResponse struct {
+	Status  string `json:"status"`
+	Storage string `json:"storage,omitempty"`
+}
+
+// HealthHandler обслуживает пробы оркестратора.
+// После SetShuttingDown готовность сразу переходит в 503, чтобы балансировщик
+// перестал направлять запросы, пока сервер дорабатывает активные.
+type HealthHandler struct {
+	storage      Storage
+	shuttingDown atomic.Bool
+	logger       *slog.Logger
+}
+
+func NewHealthHandler(storage Storage, logger *slog.Logger) *HealthHandler {
+	return &HealthHandler{
+		storage: storage,
+		logger:  logger.With("component", "handler.health"),
+	}
+}
+
+// SetShuttingDown отмечает начало остановки сервиса.
+func (h *HealthHandler) SetShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// Ping обрабатывает GET /ping: 200, если хранилище доступно, иначе 500.
+func (h *HealthHandler) Ping(w http.ResponseWriter, r *http.Request) {
+	if err := h.pingStorage(r.Context()); err != nil {
+		h.logger.Error("Storage ping failed", "error", err)
+		writeJSONResponse(w, http.StatusInternalServerError,
+			HealthResponse{Status: healthStatusError, Storage: healthStatusError}, h.logger)
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, HealthResponse{Status: healthStatusOK, Storage: healthStatusOK}, h.logger)
+}
+
+// Healthz обрабатывает GET /healthz: процесс жив, пока способен ответить.
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSONResponse(w, http.StatusOK, HealthResponse{Status: healthStatusOK}, h.logger)
+}
+
+// Readyz обрабатывает GET /readyz: 200, если хранилище доступно и остановка не началась,
+// иначе 503.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	if h.shuttingDown.Load() {
+		writeJSONResponse(w, http.StatusServiceUnavailable, HealthResponse{Status: healthStatusShuttingDown}, h.logger)
+		return
+	}
+	if err := h.pingStorage(r.Context()); err != nil {
+		h.logger.Warn("Not ready: storage ping failed", "error", err)
+		writeJSONResponse(w, http.StatusServiceUnavailable,
+			HealthResponse{Status: healthStatusError, Storage: healthStatusError}, h.logger)
+		return
+	}
+	writeJSONResponse(w, http.StatusOK, HealthResponse{Status: healthStatusOK, Storage: healthStatusOK}, h.logger)
+}
+
+// pingStorage проверяет хранилище, если оно поддерживает Pinger.
+func (h *HealthHandler) pingStorage(ctx context.Context) error {
+	pinger, ok := storageCapability[Pinger](h.storage)
+	if !ok {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	return pinger.Ping(ctx)
+}
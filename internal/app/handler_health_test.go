@@ -0,0 +1,112 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pingerStorage - хранилище в памяти с управляемым результатом Ping.
+type pingerStorage struct {
+	*InMemoryStorage
+	err error
+}
+
+func (s *pingerStorage) Ping(ctx context.Context) error {
+	return s.err
+}
+
+func TestHealthHandler(t *testing.T) {
+	testCases := []struct {
+		name           string
+		storage        Storage
+		shuttingDown   bool
+		handler        func(h *HealthHandler) http.HandlerFunc
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Ping without Pinger",
+			storage:        NewInMemoryStorage(),
+			handler:        func(h *HealthHandler) http.HandlerFunc { return h.Ping },
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"ok","storage":"ok"}`,
+		},
+		{
+			name:           "Ping storage down",
+			storage:        &pingerStorage{InMemoryStorage: NewInMemoryStorage(), err: errors.New("connection refused")},
+			handler:        func(h *HealthHandler) http.HandlerFunc { return h.Ping },
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"status":"error","storage":"error"}`,
+		},
+		{
+			name:           "Healthz ignores storage",
+			storage:        &pingerStorage{InMemoryStorage: NewInMemoryStorage(), err: errors.New("connection refused")},
+			handler:        func(h *HealthHandler) http.HandlerFunc { return h.Healthz },
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"ok"}`,
+		},
+		{
+			name:           "Ready",
+			storage:        &pingerStorage{InMemoryStorage: NewInMemoryStorage()},
+			handler:        func(h *HealthHandler) http.HandlerFunc { return h.Readyz },
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"ok","storage":"ok"}`,
+		},
+		{
+			name:           "Not ready: storage down",
+			storage:        &pingerStorage{InMemoryStorage: NewInMemoryStorage(), err: errors.New("connection refused")},
+			handler:        func(h *HealthHandler) http.HandlerFunc { return h.Readyz },
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   `{"status":"error","storage":"error"}`,
+		},
+		{
+			name:           "Not ready: shutting down",
+			storage:        &pingerStorage{InMemoryStorage: NewInMemoryStorage()},
+			shuttingDown:   true,
+			handler:        func(h *HealthHandler) http.HandlerFunc { return h.Readyz },
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   `{"status":"shutting_down"}`,
+		},
+		{
+			name:           "Alive while shutting down",
+			storage:        NewInMemoryStorage(),
+			shuttingDown:   true,
+			handler:        func(h *HealthHandler) http.HandlerFunc { return h.Healthz },
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"ok"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := NewHealthHandler(tc.storage, logger.Discard())
+			if tc.shuttingDown {
+				h.SetShuttingDown()
+			}
+
+			rr := httptest.NewRecorder()
+			tc.handler(h)(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			result := rr.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tc.expectedStatus, result.StatusCode, "Неверный статус код")
+			assert.Equal(t, "application/json", result.Header.Get("Content-Type"))
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.expectedBody, string(body), "Неверное тело ответа")
+		})
+	}
+}
+
+func TestSQLiteStorage_Ping(t *testing.T) {
+	s := newTestSQLiteStorage(t)
+	assert.NoError(t, s.Ping(context.Background()))
+}
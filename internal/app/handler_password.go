@@ -0,0 +1,122 @@
+package app
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// PasswordHeader - заголовок с паролем защищенной ссылки для GET /api/links/{id}.
+	PasswordHeader = "X-Link-Password"
+	// maxPasswordFormSize ограничивает тело формы ввода пароля.
+	maxPasswordFormSize = 4 << 10
+)
+
+// passwordPromptTemplate - страница ввода пароля; форма отправляется POST на тот же адрес.
+var passwordPromptTemplate = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Password required</title>
+</head>
+<body>
+<form method="post">
+<p>This link is protected with a password.</p>
+{{if .Error}}<p role="alert">{{.Error}}</p>
+{{end}}<input type="password" name="password" autocomplete="current-password" maxlength="72" required autofocus>
+<button type="submit">Open</button>
+</form>
+</body>
+</html>
+`))
+
+// passwordPrompt - данные страницы ввода пароля.
+type passwordPrompt struct {
+	Error string
+}
+
+// ResolveResponse - тело ответа GET /api/links/{id}.
+type ResolveResponse struct {
+	OriginalURL string `json:"original_url"`
+}
+
+// renderPasswordPrompt отправляет страницу ввода пароля с сообщением об ошибке message, если оно есть.
+func (h *Handler) renderPasswordPrompt(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := passwordPromptTemplate.Execute(w, passwordPrompt{Error: message}); err != nil {
+		h.logger.Error("Failed to render password prompt", "error", err)
+	}
+}
+
+// setRetryAfter подсказывает клиенту, когда попытки ввести пароль снова станут доступны.
+func setRetryAfter(w http.ResponseWriter) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(unlockAttemptsWindow.Seconds())))
+}
+
+// Unlock обрабатывает POST /{id} - отправку формы ввода пароля защищенной ссылки.
+// Верный пароль перенаправляет на исходный URL со статусом 303, неверный возвращает
+// форму со статусом 403, а сверх лимита попыток - со статусом 429.
+func (h *Handler) Unlock(w http.ResponseWriter, r *http.Request) {
+	shortID := chi.URLParam(r, "id")
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasswordFormSize)
+	if err := r.ParseForm(); err != nil {
+		h.logger.Warn("Failed to parse password form", "id", shortID, "error", err)
+		http.Error(w, "Invalid form body", http.StatusBadRequest)
+		return
+	}
+
+	originalURL, err := h.service.UnlockURL(r.Context(), shortID, r.PostForm.Get("password"), clientIP(r))
+	switch {
+	case errors.Is(err, ErrWrongPassword):
+		h.logger.Info("Wrong password for ID", "id", shortID)
+		h.renderPasswordPrompt(w, http.StatusForbidden, "Wrong password")
+	case errors.Is(err, ErrTooManyAttempts):
+		h.logger.Warn("Too many password attempts for ID", "id", shortID)
+		setRetryAfter(w)
+		h.renderPasswordPrompt(w, http.StatusTooManyRequests, "Too many attempts, try again later")
+	case err != nil:
+		status, message := h.linkError(shortID, err)
+		http.Error(w, message, status)
+	default:
+		h.recordClick(r, shortID)
+		http.Redirect(w, r, originalURL, http.StatusSeeOther)
+	}
+}
+
+// ResolveJSON обрабатывает GET /api/links/{id}: засчитывает переход по ссылке и
+// возвращает исходный URL в JSON вместо перенаправления. Пароль защищенной ссылки
+// передается в заголовке X-Link-Password; без него ответ 401, с неверным - 403,
+// сверх лимита попыток - 429.
+func (h *Handler) ResolveJSON(w http.ResponseWriter, r *http.Request) {
+	shortID := chi.URLParam(r, "id")
+
+	var (
+		originalURL string
+		err         error
+	)
+	if password := r.Header.Get(PasswordHeader); password != "" {
+		originalURL, err = h.service.UnlockURL(r.Context(), shortID, password, clientIP(r))
+	} else {
+		originalURL, err = h.service.GetOriginalURL(r.Context(), shortID)
+	}
+	if err != nil {
+		if errors.Is(err, ErrTooManyAttempts) {
+			setRetryAfter(w)
+		}
+		status, message := h.linkError(shortID, err)
+		h.writeJSONError(w, status, message)
+		return
+	}
+
+	h.recordClick(r, shortID)
+	w.Header().Set("Cache-Control", "no-store")
+	h.writeJSON(w, http.StatusOK, ResolveResponse{OriginalURL: originalURL})
+}
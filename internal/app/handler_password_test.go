@@ -0,0 +1,241 @@
+package app
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// withIDParam добавляет в запрос параметр маршрута id, как это делает chi.
+func withIDParam(req *http.Request, id string) *http.Request {
+	routeCtx := chi.NewRouteContext()
+	routeCtx.URLParams.Add("id", id)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
+}
+
+func TestHandler_Redirect_PasswordPrompt(t *testing.T) {
+	mockService := new(MockShortenerService)
+	mockService.On("GetOriginalURL", mock.Anything, "abcdef12").Return("", ErrPasswordRequired).Once()
+	handler := NewHandler(mockService, nil, "http://dummy.base", logger.Discard())
+
+	rr := httptest.NewRecorder()
+	handler.Redirect(rr, withIDParam(httptest.NewRequest(http.MethodGet, "/abcdef12", nil), "abcdef12"))
+
+	result := rr.Result()
+	defer result.Body.Close()
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", result.Header.Get("Content-Type"))
+	assert.Equal(t, "no-store", result.Header.Get("Cache-Control"))
+	body, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `<form method="post">`)
+	assert.Contains(t, string(body), `name="password"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestHandler_Unlock(t *testing.T) {
+	const validID = "abcdef12"
+
+	testCases := []struct {
+		name             string
+		body             string
+		mockPassword     string
+		mockReturnURL    string
+		mockReturnErr    error
+		expectedStatus   int
+		expectedLocation string
+		expectedBody     string
+	}{
+		{
+			name:             "Correct Password",
+			body:             "password=s3cret",
+			mockPassword:     "s3cret",
+			mockReturnURL:    "https://yandex.ru",
+			expectedStatus:   http.StatusSeeOther,
+			expectedLocation: "https://yandex.ru",
+		},
+		{
+			name:           "Wrong Password",
+			body:           "password=guess",
+			mockPassword:   "guess",
+			mockReturnErr:  ErrWrongPassword,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   "Wrong password",
+		},
+		{
+			name:           "Too Many Attempts",
+			body:           "password=guess",
+			mockPassword:   "guess",
+			mockReturnErr:  ErrTooManyAttempts,
+			expectedStatus: http.StatusTooManyRequests,
+			expectedBody:   "Too many attempts",
+		},
+		{
+			name:           "Expired ID",
+			body:           "password=s3cret",
+			mockPassword:   "s3cret",
+			mockReturnErr:  ErrExpired,
+			expectedStatus: http.StatusGone,
+			expectedBody:   "URL expired",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			mockService.On("UnlockURL", mock.Anything, validID, tc.mockPassword, mock.Anything).
+				Return(tc.mockReturnURL, tc.mockReturnErr).
+				Once()
+			handler := NewHandler(mockService, nil, "http://dummy.base", logger.Discard())
+
+			req := httptest.NewRequest(http.MethodPost, "/"+validID, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			rr := httptest.NewRecorder()
+			handler.Unlock(rr, withIDParam(req, validID))
+
+			result := rr.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tc.expectedStatus, result.StatusCode)
+			assert.Equal(t, tc.expectedLocation, result.Header.Get("Location"))
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			assert.Contains(t, string(body), tc.expectedBody)
+			if tc.expectedStatus == http.StatusTooManyRequests {
+				assert.NotEmpty(t, result.Header.Get("Retry-After"))
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandler_Unlock_OversizedForm(t *testing.T) {
+	mockService := new(MockShortenerService)
+	handler := NewHandler(mockService, nil, "http://dummy.base", logger.Discard())
+
+	body := url.Values{"password": {strings.Repeat("x", maxPasswordFormSize)}}.Encode()
+	req := httptest.NewRequest(http.MethodPost, "/abcdef12", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr := httptest.NewRecorder()
+	handler.Unlock(rr, withIDParam(req, "abcdef12"))
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockService.AssertNotCalled(t, "UnlockURL", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandler_ResolveJSON(t *testing.T) {
+	const validID = "abcdef12"
+
+	testCases := []struct {
+		name           string
+		password       string
+		mockReturnURL  string
+		mockReturnErr  error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Public Link",
+			mockReturnURL:  "https://yandex.ru",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"original_url":"https://yandex.ru"}`,
+		},
+		{
+			name:           "Password Required",
+			mockReturnErr:  ErrPasswordRequired,
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Password required"}`,
+		},
+		{
+			name:           "Correct Password",
+			password:       "s3cret",
+			mockReturnURL:  "https://yandex.ru",
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"original_url":"https://yandex.ru"}`,
+		},
+		{
+			name:           "Wrong Password",
+			password:       "guess",
+			mockReturnErr:  ErrWrongPassword,
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"error":"Wrong password"}`,
+		},
+		{
+			name:           "Too Many Attempts",
+			password:       "guess",
+			mockReturnErr:  ErrTooManyAttempts,
+			expectedStatus: http.StatusTooManyRequests,
+			expectedBody:   `{"error":"Too many password attempts, try again later"}`,
+		},
+		{
+			name:           "Not Found",
+			mockReturnErr:  ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"URL not found"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			if tc.password != "" {
+				mockService.On("UnlockURL", mock.Anything, validID, tc.password, mock.Anything).
+					Return(tc.mockReturnURL, tc.mockReturnErr).
+					Once()
+			} else {
+				mockService.On("GetOriginalURL", mock.Anything, validID).
+					Return(tc.mockReturnURL, tc.mockReturnErr).
+					Once()
+			}
+			handler := NewHandler(mockService, nil, "http://dummy.base", logger.Discard())
+
+			req := httptest.NewRequest(http.MethodGet, "/api/links/"+validID, nil)
+			if tc.password != "" {
+				req.Header.Set(PasswordHeader, tc.password)
+			}
+			rr := httptest.NewRecorder()
+			handler.ResolveJSON(rr, withIDParam(req, validID))
+
+			result := rr.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tc.expectedStatus, result.StatusCode)
+			assert.Equal(t, "application/json", result.Header.Get("Content-Type"))
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.expectedBody, string(body))
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandler_ShortenJSON_PasswordForExistingURL(t *testing.T) {
+	service := NewShortenerService(NewInMemoryStorage(), newTestIDGenerator(t), 3, nil, nil, nil, logger.Discard())
+	handler := NewHandler(service, nil, "http://dummy.base", logger.Discard())
+
+	shorten := func(body string) *http.Response {
+		rr := httptest.NewRecorder()
+		handler.ShortenJSON(rr, httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(body)))
+		return rr.Result()
+	}
+
+	result := shorten(`{"url":"https://yandex.ru"}`)
+	result.Body.Close()
+	require.Equal(t, http.StatusCreated, result.StatusCode)
+
+	// Незащищенную ссылку нельзя выдать за защищенную: ни ссылки, ни молчаливого отказа от пароля.
+	result = shorten(`{"url":"https://yandex.ru","password":"s3cret"}`)
+	defer result.Body.Close()
+	assert.Equal(t, http.StatusConflict, result.StatusCode)
+	body, err := io.ReadAll(result.Body)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"error":"URL is already shortened without the requested restrictions"}`, string(body))
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cmpxNot29a/shurs/internal/auth"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// defaultReportDays - длина периода отчета в днях, если days не указан.
+	defaultReportDays = 30
+	// maxReportDays - наибольшая длина периода отчета.
+	maxReportDays = 366
+	// defaultReportTop - размер списков отчета, если top не указан.
+	defaultReportTop = 10
+	// maxReportTop - наибольший размер списков отчета.
+	maxReportTop = 100
+)
+
+// ReportResponse - тело ответа GET /api/links/{id}/report и GET /api/user/report.
+type ReportResponse struct {
+	From        string       `json:"from"`
+	To          string       `json:"to"`
+	TotalClicks int64        `json:"total_clicks"`
+	Browsers    []ReportItem `json:"browsers"`
+	OS          []ReportItem `json:"os"`
+	Devices     []ReportItem `json:"devices"`
+	Referrers   []ReportItem `json:"referrers"`
+}
+
+// ReportItem - элемент списка в ReportResponse.
+type ReportItem struct {
+	Name   string `json:"name"`
+	Clicks int64  `json:"clicks"`
+}
+
+// GetLinkReport обрабатывает GET /api/links/{id}/report?days=N&top=M: самые частые
+// браузеры, ОС, классы устройств и домены источников переходов по ссылке за
+// последние days дней UTC, включая сегодняшний. Доступ - как у GetLinkStats.
+func (h *Handler) GetLinkReport(w http.ResponseWriter, r *http.Request) {
+	if !auth.IsAuthenticated(r.Context()) {
+		h.writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	shortID := chi.URLParam(r, "id")
+
+	query, ok := h.reportQuery(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.service.GetLinkReport(r.Context(), shortID, query)
+	if errors.Is(err, ErrNotFound) {
+		h.writeJSONError(w, http.StatusNotFound, "URL not found")
+		return
+	}
+	if err != nil {
+		h.logger.Error("Service failed to get link report", "id", shortID, "error", err)
+		h.writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newReportResponse(query, report))
+}
+
+// GetUserReport обрабатывает GET /api/user/report?days=N&top=M: то же, что
+// GetLinkReport, но по всем ссылкам пользователя. Возвращает 401 без действительной cookie.
+func (h *Handler) GetUserReport(w http.ResponseWriter, r *http.Request) {
+	if !auth.IsAuthenticated(r.Context()) {
+		h.writeJSONError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+
+	query, ok := h.reportQuery(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := h.service.GetUserReport(r.Context(), query)
+	if err != nil {
+		h.logger.Error("Service failed to get user report", "error", err)
+		h.writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	h.writeJSON(w, http.StatusOK, newReportResponse(query, report))
+}
+
+// reportQuery разбирает параметры days и top. При ошибке отвечает 400 и возвращает false.
+func (h *Handler) reportQuery(w http.ResponseWriter, r *http.Request) (ReportQuery, bool) {
+	days, ok := h.queryInt(w, r, "days", defaultReportDays, maxReportDays)
+	if !ok {
+		return ReportQuery{}, false
+	}
+	top, ok := h.queryInt(w, r, "top", defaultReportTop, maxReportTop)
+	if !ok {
+		return ReportQuery{}, false
+	}
+
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	return ReportQuery{From: to.AddDate(0, 0, 1-days), To: to, Top: top}, true
+}
+
+// queryInt возвращает целый параметр запроса name из диапазона 1..maxValue или
+// defaultValue, если параметра нет. При ошибке отвечает 400 и возвращает false.
+func (h *Handler) queryInt(w http.ResponseWriter, r *http.Request, name string, defaultValue, maxValue int) (int, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return defaultValue, true
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value <= 0 || value > maxValue {
+		h.writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("%s must be between 1 and %d", name, maxValue))
+		return 0, false
+	}
+	return value, true
+}
+
+func newReportResponse(query ReportQuery, report Breakdown) ReportResponse {
+	return ReportResponse{
+		From:        query.From.Format(statsDayLayout),
+		To:          query.To.Format(statsDayLayout),
+		TotalClicks: report.TotalClicks,
+		Browsers:    newReportItems(report.Browsers),
+		OS:          newReportItems(report.OS),
+		Devices:     newReportItems(report.Devices),
+		Referrers:   newReportItems(report.Referrers),
+	}
+}
+
+// newReportItems конвертирует список разбивки; пустой список сериализуется как [], а не null.
+func newReportItems(items []BreakdownItem) []ReportItem {
+	result := make([]ReportItem, len(items))
+	for i, item := range items {
+		result[i] = ReportItem{Name: item.Name, Clicks: item.Clicks}
+	}
+	return result
+}
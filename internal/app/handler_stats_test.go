@@ -0,0 +1,268 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cmpxNot29a/shurs/internal/auth"
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler_GetLinkStats(t *testing.T) {
+	const validID = "abcdef12"
+
+	testCases := []struct {
+		name           string
+		authenticated  bool
+		callService    bool
+		mockStats      LinkStats
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Unauthenticated",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Unauthorized"}`,
+		},
+		{
+			name:          "Stats",
+			authenticated: true,
+			callService:   true,
+			mockStats: LinkStats{TotalClicks: 3, UniqueVisitors: 2,
+				Daily: []DailyClicks{{Date: "2026-10-17", Clicks: 1}, {Date: "2026-10-18", Clicks: 2}}},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"total_clicks":3,"unique_visitors":2,
+				"daily":[{"date":"2026-10-17","clicks":1},{"date":"2026-10-18","clicks":2}]}`,
+		},
+		{
+			name:           "No Clicks",
+			authenticated:  true,
+			callService:    true,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"total_clicks":0,"unique_visitors":0,"daily":[]}`,
+		},
+		{
+			name:           "Not Found Or Foreign",
+			authenticated:  true,
+			callService:    true,
+			mockErr:        ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"URL not found"}`,
+		},
+		{
+			name:           "Service Error",
+			authenticated:  true,
+			callService:    true,
+			mockErr:        errors.New("analytics unavailable"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal server error"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			handler := NewHandler(mockService, nil, "http://dummy.base", logger.Discard())
+			if tc.callService {
+				mockService.On("GetLinkStats", mock.Anything, validID).Return(tc.mockStats, tc.mockErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/links/"+validID+"/stats", nil)
+			if tc.authenticated {
+				req = req.WithContext(auth.WithUserID(req.Context(), "user-1"))
+			} else {
+				req = req.WithContext(auth.WithIssuedUserID(req.Context(), "user-new"))
+			}
+			rr := httptest.NewRecorder()
+			handler.GetLinkStats(rr, withIDParam(req, validID))
+
+			result := rr.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tc.expectedStatus, result.StatusCode)
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			assert.JSONEq(t, tc.expectedBody, string(body))
+			if tc.callService {
+				mockService.AssertExpectations(t)
+			} else {
+				mockService.AssertNotCalled(t, "GetLinkStats", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestHandler_RedirectRecordsClick(t *testing.T) {
+	ctx := context.Background()
+	store := NewInMemoryAnalytics()
+	clicks := NewClickRecorder(store, nil, []byte("key"), 1000, time.Hour, nil, logger.Discard())
+	mockService := new(MockShortenerService)
+	mockService.On("GetOriginalURL", mock.Anything, "abcdef12").Return("https://yandex.ru", nil).Once()
+	mockService.On("GetOriginalURL", mock.Anything, "notexist").Return("", ErrNotFound).Once()
+	handler := NewHandler(mockService, clicks, "http://dummy.base", logger.Discard())
+
+	for _, id := range []string{"abcdef12", "notexist"} {
+		req := httptest.NewRequest(http.MethodGet, "/"+id, nil)
+		req.Header.Set("Referer", "https://ya.ru/search")
+		req.Header.Set("User-Agent", "test-agent")
+		handler.Redirect(httptest.NewRecorder(), withIDParam(req, id))
+	}
+	require.NoError(t, clicks.Shutdown(ctx))
+
+	stats, err := store.Stats(ctx, LinkRef{ID: "abcdef12"})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, stats.TotalClicks)
+	assert.EqualValues(t, 1, stats.UniqueVisitors)
+	stats, err = store.Stats(ctx, LinkRef{ID: "notexist"})
+	require.NoError(t, err)
+	assert.Zero(t, stats.TotalClicks, "неудачный переход не записывается")
+}
+
+func TestHandler_GetLinkReport(t *testing.T) {
+	const validID = "abcdef12"
+
+	testCases := []struct {
+		name           string
+		authenticated  bool
+		query          string
+		callService    bool
+		expectedDays   int
+		expectedTop    int
+		mockReport     Breakdown
+		mockErr        error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Unauthenticated",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Unauthorized"}`,
+		},
+		{
+			name:          "Defaults",
+			authenticated: true,
+			callService:   true,
+			expectedDays:  defaultReportDays,
+			expectedTop:   defaultReportTop,
+			mockReport: Breakdown{
+				TotalClicks: 3,
+				Browsers:    []BreakdownItem{{Name: "Chrome", Clicks: 2}, {Name: "Firefox", Clicks: 1}},
+				Devices:     []BreakdownItem{{Name: DeviceDesktop, Clicks: 3}},
+				Referrers:   []BreakdownItem{{Name: "ya.ru", Clicks: 3}},
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"total_clicks":3,"browsers":[{"name":"Chrome","clicks":2},{"name":"Firefox","clicks":1}],
+				"os":[],"devices":[{"name":"desktop","clicks":3}],"referrers":[{"name":"ya.ru","clicks":3}]}`,
+		},
+		{
+			name:           "Custom Window",
+			authenticated:  true,
+			query:          "?days=7&top=3",
+			callService:    true,
+			expectedDays:   7,
+			expectedTop:    3,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"total_clicks":0,"browsers":[],"os":[],"devices":[],"referrers":[]}`,
+		},
+		{
+			name:           "Invalid Days",
+			authenticated:  true,
+			query:          "?days=0",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"days must be between 1 and 366"}`,
+		},
+		{
+			name:           "Invalid Top",
+			authenticated:  true,
+			query:          "?top=abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"top must be between 1 and 100"}`,
+		},
+		{
+			name:           "Not Found Or Foreign",
+			authenticated:  true,
+			callService:    true,
+			expectedDays:   defaultReportDays,
+			expectedTop:    defaultReportTop,
+			mockErr:        ErrNotFound,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"error":"URL not found"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockService := new(MockShortenerService)
+			handler := NewHandler(mockService, nil, "http://dummy.base", logger.Discard())
+			var window ReportQuery
+			if tc.callService {
+				mockService.On("GetLinkReport", mock.Anything, validID, mock.MatchedBy(func(q ReportQuery) bool {
+					window = q
+					return q.Top == tc.expectedTop && q.To.Sub(q.From) == time.Duration(tc.expectedDays-1)*24*time.Hour
+				})).Return(tc.mockReport, tc.mockErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/links/"+validID+"/report"+tc.query, nil)
+			if tc.authenticated {
+				req = req.WithContext(auth.WithUserID(req.Context(), "user-1"))
+			} else {
+				req = req.WithContext(auth.WithIssuedUserID(req.Context(), "user-new"))
+			}
+			rr := httptest.NewRecorder()
+			handler.GetLinkReport(rr, withIDParam(req, validID))
+
+			result := rr.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tc.expectedStatus, result.StatusCode)
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			if tc.expectedStatus == http.StatusOK {
+				var resp map[string]any
+				require.NoError(t, json.Unmarshal(body, &resp))
+				assert.Equal(t, window.From.Format(statsDayLayout), resp["from"])
+				assert.Equal(t, time.Now().UTC().Format(statsDayLayout), resp["to"])
+				delete(resp, "from")
+				delete(resp, "to")
+				body, err = json.Marshal(resp)
+				require.NoError(t, err)
+			}
+			assert.JSONEq(t, tc.expectedBody, string(body))
+			if tc.callService {
+				mockService.AssertExpectations(t)
+			} else {
+				mockService.AssertNotCalled(t, "GetLinkReport", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestHandler_GetUserReport(t *testing.T) {
+	mockService := new(MockShortenerService)
+	handler := NewHandler(mockService, nil, "http://dummy.base", logger.Discard())
+	mockService.On("GetUserReport", mock.Anything, mock.Anything).
+		Return(Breakdown{TotalClicks: 1, OS: []BreakdownItem{{Name: "iOS", Clicks: 1}}}, nil).Once()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/user/report", nil)
+	rr := httptest.NewRecorder()
+	handler.GetUserReport(rr, req.WithContext(auth.WithIssuedUserID(req.Context(), "user-new")))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	rr = httptest.NewRecorder()
+	handler.GetUserReport(rr, req.WithContext(auth.WithUserID(req.Context(), "user-1")))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	var resp ReportResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	assert.EqualValues(t, 1, resp.TotalClicks)
+	assert.Equal(t, []ReportItem{{Name: "iOS", Clicks: 1}}, resp.OS)
+	assert.Empty(t, resp.Browsers)
+	mockService.AssertExpectations(t)
+}
@@ -1,17 +1,24 @@
 package app
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/cmpxNot29a/shurs/internal/auth"
 	"github.com/cmpxNot29a/shurs/internal/helper"
+	"github.com/cmpxNot29a/shurs/internal/logger"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -30,11 +37,50 @@ func (m *MockShortenerService) CreateShortURL(ctx context.Context, originalURL s
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockShortenerService) CreateLink(ctx context.Context, originalURL string, opts LinkOptions) (string, error) {
+	args := m.Called(ctx, originalURL, opts)
+	return args.String(0), args.Error(1)
+}
+
 func (m *MockShortenerService) GetOriginalURL(ctx context.Context, id string) (string, error) {
 	args := m.Called(ctx, id)
 	return args.String(0), args.Error(1)
 }
 
+func (m *MockShortenerService) UnlockURL(ctx context.Context, id, password, client string) (string, error) {
+	args := m.Called(ctx, id, password, client)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockShortenerService) GetUserURLs(ctx context.Context, cursor string, limit int) (URLPage, error) {
+	args := m.Called(ctx, cursor, limit)
+	return args.Get(0).(URLPage), args.Error(1)
+}
+
+func (m *MockShortenerService) GetLinkStats(ctx context.Context, id string) (LinkStats, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(LinkStats), args.Error(1)
+}
+
+func (m *MockShortenerService) GetLinkReport(ctx context.Context, id string, query ReportQuery) (Breakdown, error) {
+	args := m.Called(ctx, id, query)
+	return args.Get(0).(Breakdown), args.Error(1)
+}
+
+func (m *MockShortenerService) GetUserReport(ctx context.Context, query ReportQuery) (Breakdown, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).(Breakdown), args.Error(1)
+}
+
+func (m *MockShortenerService) DeleteUserURLs(ctx context.Context, ids []string) error {
+	return m.Called(ctx, ids).Error(0)
+}
+
+func (m *MockShortenerService) CreateShortURLBatch(ctx context.Context, originalURLs []string) ([]string, error) {
+	args := m.Called(ctx, originalURLs)
+	ids, _ := args.Get(0).([]string)
+	return ids, args.Error(1)
+}
 
 func TestHandler_CreateShortURL(t *testing.T) {
 	testCases := []struct {
@@ -60,6 +106,17 @@ func TestHandler_CreateShortURL(t *testing.T) {
 			expectedBodyPrefix:  "http://test.co/",
 			testBaseURL:         "http://test.co",
 		},
+		{
+			name:                "Already Shortened URL - Conflict",
+			method:              http.MethodPost,
+			body:                "https://ya.ru",
+			mockReturnID:        "eXiSt123",
+			mockReturnErr:       ErrURLExists,
+			expectedStatus:      http.StatusConflict,
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBodyPrefix:  "http://test.co/",
+			testBaseURL:         "http://test.co",
+		},
 		{
 			name:                "Service Error on Create",
 			method:              http.MethodPost,
@@ -92,7 +149,7 @@ func TestHandler_CreateShortURL(t *testing.T) {
 			var mockService ShortenerUseCase = mockServicePtr // Присваиваем интерфейсу
 
 			// 2. Создаем хендлер, передавая интерфейс
-			handler := NewHandler(mockService, tc.testBaseURL)
+			handler := NewHandler(mockService, nil, tc.testBaseURL, logger.Discard())
 
 			// 3. Настраиваем ожидания мока (используя указатель на мок)
 			if tc.expectedStatus != http.StatusBadRequest {
@@ -107,7 +164,7 @@ func TestHandler_CreateShortURL(t *testing.T) {
 			rr := httptest.NewRecorder()
 
 			// 5. Вызываем middleware + handler
-			handlerWithMiddleware := ValidateURLMiddleware(http.HandlerFunc(handler.CreateShortURL))
+			handlerWithMiddleware := ValidateURLMiddleware(logger.Discard())(http.HandlerFunc(handler.CreateShortURL))
 			handlerWithMiddleware.ServeHTTP(rr, req)
 
 			// 6. Проверяем HTTP ответ
@@ -117,7 +174,7 @@ func TestHandler_CreateShortURL(t *testing.T) {
 			if tc.expectedStatus != http.StatusBadRequest {
 				assert.Contains(t, result.Header.Get("Content-Type"), "text/plain", "Неверный Content-Type")
 			}
-			if tc.expectedStatus == http.StatusCreated {
+			if tc.expectedStatus == http.StatusCreated || tc.expectedStatus == http.StatusConflict {
 				responseBodyBytes, err := io.ReadAll(result.Body)
 				require.NoError(t, err, "Не удалось прочитать тело ответа")
 				expectedBody := tc.testBaseURL + "/" + tc.mockReturnID
@@ -159,6 +216,18 @@ func TestHandler_Redirect(t *testing.T) {
 			expectedStatus:   http.StatusTemporaryRedirect,
 			expectedLocation: testURL,
 		},
+		{
+			name:             "Alias - Success",
+			requestID:        "spring-sale",
+			mockReturnURL:    testURL,
+			expectedStatus:   http.StatusTemporaryRedirect,
+			expectedLocation: testURL,
+		},
+		{
+			name:           "Reserved Alias (Handled by Middleware)",
+			requestID:      "metrics",
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name:             "ID Not Found (Service returns ErrNotFound)",
 			requestID:        notFoundID,
@@ -167,6 +236,24 @@ func TestHandler_Redirect(t *testing.T) {
 			expectedStatus:   http.StatusNotFound,
 			expectedLocation: "",
 		},
+		{
+			name:           "Deleted ID",
+			requestID:      validID,
+			mockReturnErr:  ErrDeleted,
+			expectedStatus: http.StatusGone,
+		},
+		{
+			name:           "Expired ID",
+			requestID:      validID,
+			mockReturnErr:  ErrExpired,
+			expectedStatus: http.StatusGone,
+		},
+		{
+			name:           "Click Limit Reached",
+			requestID:      validID,
+			mockReturnErr:  ErrClicksExhausted,
+			expectedStatus: http.StatusGone,
+		},
 		{
 			name:           "Invalid ID Format (Handled by Middleware)",
 			requestID:      invalidFormatID,
@@ -194,7 +281,7 @@ func TestHandler_Redirect(t *testing.T) {
 			var mockService ShortenerUseCase = mockServicePtr
 
 			// 2. Создаем хендлер
-			handler := NewHandler(mockService, "http://dummy.base")
+			handler := NewHandler(mockService, nil, "http://dummy.base", logger.Discard())
 
 			// 3. Настраиваем ожидания мока
 			if tc.expectedStatus != http.StatusBadRequest {
@@ -211,7 +298,7 @@ func TestHandler_Redirect(t *testing.T) {
 			req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, routeCtx))
 
 			// 5. Вызываем middleware + handler
-			middlewareFunc := ValidateIDMiddleware(expectedIDLength)
+			middlewareFunc := ValidateIDMiddleware(IDFormat{Alphabet: helper.Base62Alphabet, MinLength: expectedIDLength, MaxLength: expectedIDLength}, logger.Discard())
 			handlerWithMiddleware := middlewareFunc(http.HandlerFunc(handler.Redirect))
 			handlerWithMiddleware.ServeHTTP(rr, req)
 
@@ -232,6 +319,628 @@ func TestHandler_Redirect(t *testing.T) {
 	}
 }
 
+func TestHandler_ShortenJSON(t *testing.T) {
+	const testBaseURL = "http://test.co"
+
+	testCases := []struct {
+		name           string
+		body           string
+		callService    bool
+		mockURL        string
+		mockOpts       LinkOptions
+		mockReturnID   string
+		mockReturnErr  error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Valid URL - Success",
+			body:           `{"url":"https://yandex.ru"}`,
+			callService:    true,
+			mockURL:        "https://yandex.ru",
+			mockReturnID:   "aBcDeF12",
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"result":"http://test.co/aBcDeF12"}`,
+		},
+		{
+			name:           "Already Shortened URL - Conflict",
+			body:           `{"url":"https://ya.ru"}`,
+			callService:    true,
+			mockURL:        "https://ya.ru",
+			mockReturnID:   "eXiSt123",
+			mockReturnErr:  ErrURLExists,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"result":"http://test.co/eXiSt123"}`,
+		},
+		{
+			name:           "Alias - Success",
+			body:           `{"url":"https://yandex.ru/sale","alias":"spring-sale"}`,
+			callService:    true,
+			mockURL:        "https://yandex.ru/sale",
+			mockOpts:       LinkOptions{Alias: "spring-sale"},
+			mockReturnID:   "spring-sale",
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"result":"http://test.co/spring-sale"}`,
+		},
+		{
+			name:           "Alias Taken - Conflict",
+			body:           `{"url":"https://yandex.ru/sale","alias":"spring-sale"}`,
+			callService:    true,
+			mockURL:        "https://yandex.ru/sale",
+			mockOpts:       LinkOptions{Alias: "spring-sale"},
+			mockReturnErr:  ErrConflict,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"Alias is already taken"}`,
+		},
+		{
+			name:           "Invalid Alias",
+			body:           `{"url":"https://yandex.ru/sale","alias":"api"}`,
+			callService:    true,
+			mockURL:        "https://yandex.ru/sale",
+			mockOpts:       LinkOptions{Alias: "api"},
+			mockReturnErr:  ErrInvalidAlias,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid alias"}`,
+		},
+		{
+			name:           "Expires At - Success",
+			body:           `{"url":"https://yandex.ru/sale","expires_at":"2999-01-01T00:00:00Z"}`,
+			callService:    true,
+			mockURL:        "https://yandex.ru/sale",
+			mockOpts:       LinkOptions{ExpiresAt: time.Date(2999, 1, 1, 0, 0, 0, 0, time.UTC)},
+			mockReturnID:   "aBcDeF12",
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"result":"http://test.co/aBcDeF12"}`,
+		},
+		{
+			name:           "Expires At In The Past",
+			body:           `{"url":"https://yandex.ru/sale","expires_at":"2000-01-01T00:00:00Z"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"expiration must be in the future"}`,
+		},
+		{
+			name:           "Both Expires At And TTL",
+			body:           `{"url":"https://yandex.ru/sale","expires_at":"2999-01-01T00:00:00Z","ttl":"1h"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"only one of expires_at and ttl may be set"}`,
+		},
+		{
+			name:           "Negative TTL",
+			body:           `{"url":"https://yandex.ru/sale","ttl":"-1h"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"expiration must be in the future"}`,
+		},
+		{
+			name:           "One-Time Link",
+			body:           `{"url":"https://yandex.ru/sale","max_clicks":1}`,
+			callService:    true,
+			mockURL:        "https://yandex.ru/sale",
+			mockOpts:       LinkOptions{MaxClicks: 1},
+			mockReturnID:   "aBcDeF12",
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"result":"http://test.co/aBcDeF12"}`,
+		},
+		{
+			name:           "Negative Max Clicks",
+			body:           `{"url":"https://yandex.ru/sale","max_clicks":-1}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"max_clicks must be positive"}`,
+		},
+		{
+			name:           "Password Protected Link",
+			body:           `{"url":"https://yandex.ru/sale","password":"s3cret"}`,
+			callService:    true,
+			mockURL:        "https://yandex.ru/sale",
+			mockOpts:       LinkOptions{Password: "s3cret"},
+			mockReturnID:   "aBcDeF12",
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"result":"http://test.co/aBcDeF12"}`,
+		},
+		{
+			name:           "Password For Already Shortened URL - Conflict",
+			body:           `{"url":"https://ya.ru","password":"s3cret"}`,
+			callService:    true,
+			mockURL:        "https://ya.ru",
+			mockOpts:       LinkOptions{Password: "s3cret"},
+			mockReturnErr:  ErrRestrictedURLExists,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   `{"error":"URL is already shortened without the requested restrictions"}`,
+		},
+		{
+			name:           "Invalid Password",
+			body:           `{"url":"https://yandex.ru/sale","password":"` + strings.Repeat("x", MaxPasswordLength+1) + `"}`,
+			callService:    true,
+			mockURL:        "https://yandex.ru/sale",
+			mockOpts:       LinkOptions{Password: strings.Repeat("x", MaxPasswordLength+1)},
+			mockReturnErr:  ErrInvalidPassword,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"invalid password"}`,
+		},
+		{
+			name:           "Service Error",
+			body:           `{"url":"https://google.com"}`,
+			callService:    true,
+			mockURL:        "https://google.com",
+			mockReturnErr:  errors.New("failed to generate unique ID"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal server error"}`,
+		},
+		{
+			name:           "Invalid URL",
+			body:           `{"url":"invalid-url"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid URL format"}`,
+		},
+		{
+			name:           "Malformed JSON",
+			body:           `{"url":`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid JSON body"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockServicePtr := new(MockShortenerService)
+			handler := NewHandler(mockServicePtr, nil, testBaseURL, logger.Discard())
+
+			if tc.callService && tc.mockOpts != (LinkOptions{}) {
+				mockServicePtr.On("CreateLink", mock.Anything, tc.mockURL, tc.mockOpts).
+					Return(tc.mockReturnID, tc.mockReturnErr).
+					Once()
+			} else if tc.callService {
+				mockServicePtr.On("CreateShortURL", mock.Anything, tc.mockURL).
+					Return(tc.mockReturnID, tc.mockReturnErr).
+					Once()
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			rr := httptest.NewRecorder()
+
+			handler.ShortenJSON(rr, req)
+
+			result := rr.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tc.expectedStatus, result.StatusCode, "Неверный статус код")
+			assert.Equal(t, "application/json", result.Header.Get("Content-Type"), "Неверный Content-Type")
+			responseBodyBytes, err := io.ReadAll(result.Body)
+			require.NoError(t, err, "Не удалось прочитать тело ответа")
+			assert.JSONEq(t, tc.expectedBody, string(responseBodyBytes), "Неверное тело ответа")
+
+			if tc.callService {
+				mockServicePtr.AssertExpectations(t)
+			} else {
+				mockServicePtr.AssertNotCalled(t, "CreateShortURL", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestHandler_ShortenBatch(t *testing.T) {
+	const testBaseURL = "http://test.co"
+
+	testCases := []struct {
+		name           string
+		body           string
+		mockURLs       []string
+		mockReturnIDs  []string
+		mockReturnErr  error
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Valid batch - Success",
+			body:           `[{"correlation_id":"a","original_url":"https://yandex.ru"},{"correlation_id":"b","original_url":"https://google.com"}]`,
+			mockURLs:       []string{"https://yandex.ru", "https://google.com"},
+			mockReturnIDs:  []string{"aBcDeF12", "gHiJkL34"},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `[{"correlation_id":"a","short_url":"http://test.co/aBcDeF12"},{"correlation_id":"b","short_url":"http://test.co/gHiJkL34"}]`,
+		},
+		{
+			name:           "Service Error",
+			body:           `[{"correlation_id":"a","original_url":"https://yandex.ru"}]`,
+			mockURLs:       []string{"https://yandex.ru"},
+			mockReturnErr:  errors.New("db is down"),
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   `{"error":"Internal server error"}`,
+		},
+		{
+			name:           "Invalid URL in batch",
+			body:           `[{"correlation_id":"a","original_url":"https://yandex.ru"},{"correlation_id":"b","original_url":"bad"}]`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid URL format for correlation_id \"b\""}`,
+		},
+		{
+			name:           "Empty batch",
+			body:           `[]`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Empty batch"}`,
+		},
+		{
+			name:           "Batch too large",
+			body:           "[" + strings.Repeat(`{"correlation_id":"a","original_url":"https://yandex.ru"},`, maxBatchURLs) + `{"correlation_id":"b","original_url":"https://ya.ru"}]`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Batch is too large: at most 1000 URLs allowed"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockServicePtr := new(MockShortenerService)
+			handler := NewHandler(mockServicePtr, nil, testBaseURL, logger.Discard())
+
+			if tc.mockURLs != nil {
+				mockServicePtr.On("CreateShortURLBatch", mock.Anything, tc.mockURLs).
+					Return(tc.mockReturnIDs, tc.mockReturnErr).
+					Once()
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(tc.body))
+			rr := httptest.NewRecorder()
+
+			handler.ShortenBatch(rr, req)
+
+			result := rr.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tc.expectedStatus, result.StatusCode, "Неверный статус код")
+			responseBodyBytes, err := io.ReadAll(result.Body)
+			require.NoError(t, err, "Не удалось прочитать тело ответа")
+			assert.JSONEq(t, tc.expectedBody, string(responseBodyBytes), "Неверное тело ответа")
+
+			if tc.mockURLs != nil {
+				mockServicePtr.AssertExpectations(t)
+			} else {
+				mockServicePtr.AssertNotCalled(t, "CreateShortURLBatch", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestHandler_Gzip(t *testing.T) {
+	const testBaseURL = "http://test.co"
+	const minSize = 64
+
+	batchBody := `[{"correlation_id":"a","original_url":"https://yandex.ru"},{"correlation_id":"b","original_url":"https://google.com"}]`
+	batchURLs := []string{"https://yandex.ru", "https://google.com"}
+	batchIDs := []string{"aBcDeF12", "gHiJkL34"}
+	batchExpected := `[{"correlation_id":"a","short_url":"http://test.co/aBcDeF12"},{"correlation_id":"b","short_url":"http://test.co/gHiJkL34"}]`
+
+	testCases := []struct {
+		name             string
+		target           string
+		body             string
+		gzipRequest      bool
+		acceptEncoding   string
+		expectGzipReply  bool
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:             "Gzip request, large response compressed",
+			target:           "/api/shorten/batch",
+			body:             batchBody,
+			gzipRequest:      true,
+			acceptEncoding:   "gzip",
+			expectGzipReply:  true,
+			expectedStatus:   http.StatusCreated,
+			expectedResponse: batchExpected,
+		},
+		{
+			name:             "Plain request, client without gzip",
+			target:           "/api/shorten/batch",
+			body:             batchBody,
+			expectGzipReply:  false,
+			expectedStatus:   http.StatusCreated,
+			expectedResponse: batchExpected,
+		},
+		{
+			name:             "Client refuses gzip with q=0",
+			target:           "/api/shorten/batch",
+			body:             batchBody,
+			acceptEncoding:   "gzip;q=0, identity",
+			expectGzipReply:  false,
+			expectedStatus:   http.StatusCreated,
+			expectedResponse: batchExpected,
+		},
+		{
+			name:             "Small response below threshold is not compressed",
+			target:           "/api/shorten",
+			body:             `{"url":"https://yandex.ru"}`,
+			gzipRequest:      true,
+			acceptEncoding:   "gzip",
+			expectGzipReply:  false,
+			expectedStatus:   http.StatusCreated,
+			expectedResponse: `{"result":"http://test.co/aBcDeF12"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockServicePtr := new(MockShortenerService)
+			handler := NewHandler(mockServicePtr, nil, testBaseURL, logger.Discard())
+			mockServicePtr.On("CreateShortURLBatch", mock.Anything, batchURLs).Return(batchIDs, nil).Maybe()
+			mockServicePtr.On("CreateShortURL", mock.Anything, "https://yandex.ru").Return("aBcDeF12", nil).Maybe()
+
+			r := chi.NewRouter()
+			r.Use(GzipMiddleware(minSize, logger.Discard()))
+			r.Post("/api/shorten", handler.ShortenJSON)
+			r.Post("/api/shorten/batch", handler.ShortenBatch)
+
+			var body io.Reader = strings.NewReader(tc.body)
+			if tc.gzipRequest {
+				body = bytes.NewReader(gzipBytes(t, tc.body))
+			}
+			req := httptest.NewRequest(http.MethodPost, tc.target, body)
+			if tc.gzipRequest {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+			rr := httptest.NewRecorder()
+
+			r.ServeHTTP(rr, req)
+
+			result := rr.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tc.expectedStatus, result.StatusCode, "Неверный статус код")
+			assert.Contains(t, result.Header.Values("Vary"), "Accept-Encoding", "Нет заголовка Vary")
+
+			var reader io.Reader = result.Body
+			if tc.expectGzipReply {
+				require.Equal(t, "gzip", result.Header.Get("Content-Encoding"), "Ответ должен быть сжат")
+				zr, err := gzip.NewReader(result.Body)
+				require.NoError(t, err)
+				defer zr.Close()
+				reader = zr
+			} else {
+				assert.Empty(t, result.Header.Get("Content-Encoding"), "Ответ не должен быть сжат")
+			}
+			responseBodyBytes, err := io.ReadAll(reader)
+			require.NoError(t, err, "Не удалось прочитать тело ответа")
+			assert.JSONEq(t, tc.expectedResponse, string(responseBodyBytes), "Неверное тело ответа")
+		})
+	}
+}
+
+func TestHandler_GzipInvalidBody(t *testing.T) {
+	mockServicePtr := new(MockShortenerService)
+	handler := NewHandler(mockServicePtr, nil, "http://test.co", logger.Discard())
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("https://yandex.ru"))
+	req.Header.Set("Content-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+
+	GzipMiddleware(0, logger.Discard())(ValidateURLMiddleware(logger.Discard())(http.HandlerFunc(handler.CreateShortURL))).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	mockServicePtr.AssertNotCalled(t, "CreateShortURL", mock.Anything, mock.Anything)
+}
+
+func TestHandler_GzipBodyTooLarge(t *testing.T) {
+	mockServicePtr := new(MockShortenerService)
+	handler := NewHandler(mockServicePtr, nil, "http://test.co", logger.Discard())
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, err := zw.Write([]byte("https://yandex.ru/?q=" + strings.Repeat("a", maxDecompressedBodySize)))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/", &compressed)
+	req.Header.Set("Content-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+
+	GzipMiddleware(0, logger.Discard())(ValidateURLMiddleware(logger.Discard())(http.HandlerFunc(handler.CreateShortURL))).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code, "распакованное тело ограничено")
+	mockServicePtr.AssertNotCalled(t, "CreateShortURL", mock.Anything, mock.Anything)
+}
+
+func TestRequestLoggerMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	jsonLogger, err := logger.New(&buf, "info", logger.FormatJSON)
+	require.NoError(t, err)
+
+	r := chi.NewRouter()
+	r.Use(middleware.RequestID)
+	r.Use(RequestLoggerMiddleware(jsonLogger))
+	r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "URL not found", http.StatusNotFound)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/abcdef12", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record), "Ожидается одна JSON-запись")
+	assert.Equal(t, "Request handled", record["msg"])
+	assert.Equal(t, http.MethodGet, record["method"])
+	assert.Equal(t, "/abcdef12", record["path"])
+	assert.EqualValues(t, http.StatusNotFound, record["status"])
+	assert.EqualValues(t, rr.Body.Len(), record["size"])
+	assert.Contains(t, record, "duration")
+	assert.NotEmpty(t, record["request_id"])
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	oldSigner, err := auth.NewSigner("old-key")
+	require.NoError(t, err)
+	signer, err := auth.NewSigner("new-key", "old-key")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name           string
+		cookie         string
+		expectedUserID string // пустая строка - ожидается новый ID
+		expectCookie   bool
+	}{
+		{name: "No cookie - new user", expectCookie: true},
+		{name: "Tampered cookie - new user", cookie: "user-1.deadbeef", expectCookie: true},
+		{name: "Valid cookie", cookie: signer.Sign("user-1"), expectedUserID: "user-1"},
+		{name: "Cookie signed with previous key is re-signed", cookie: oldSigner.Sign("user-2"), expectedUserID: "user-2", expectCookie: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var gotUserID string
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotUserID, _ = auth.UserIDFromContext(r.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: AuthCookieName, Value: tc.cookie})
+			}
+			rr := httptest.NewRecorder()
+
+			AuthMiddleware(signer, logger.Discard())(next).ServeHTTP(rr, req)
+
+			require.NotEmpty(t, gotUserID, "ID пользователя должен попасть в контекст")
+			if tc.expectedUserID != "" {
+				assert.Equal(t, tc.expectedUserID, gotUserID)
+			}
+
+			cookies := rr.Result().Cookies()
+			if !tc.expectCookie {
+				assert.Empty(t, cookies, "Cookie не должна переустанавливаться")
+				return
+			}
+			require.Len(t, cookies, 1)
+			assert.Equal(t, AuthCookieName, cookies[0].Name)
+			assert.True(t, cookies[0].HttpOnly)
+			userID, rotated, err := signer.Verify(cookies[0].Value)
+			require.NoError(t, err)
+			assert.False(t, rotated, "Cookie должна быть подписана текущим ключом")
+			assert.Equal(t, gotUserID, userID)
+		})
+	}
+}
+
+func TestHandler_GetUserURLs(t *testing.T) {
+	const testBaseURL = "http://test.co"
+
+	testCases := []struct {
+		name               string
+		authenticated      bool
+		query              string
+		callService        bool
+		expectedCursor     string
+		expectedLimit      int
+		mockPage           URLPage
+		mockErr            error
+		expectedStatus     int
+		expectedBody       string
+		expectedNextCursor string
+	}{
+		{
+			name:           "Unauthenticated",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"error":"Unauthorized"}`,
+		},
+		{
+			name:           "No links",
+			authenticated:  true,
+			callService:    true,
+			expectedLimit:  defaultUserURLsLimit,
+			expectedStatus: http.StatusNoContent,
+		},
+		{
+			name:               "First page with next cursor",
+			authenticated:      true,
+			query:              "?limit=1",
+			callService:        true,
+			expectedLimit:      1,
+			mockPage:           URLPage{Records: []URLRecord{{ID: "aBcDeF12", OriginalURL: "https://yandex.ru"}}, NextCursor: "1"},
+			expectedStatus:     http.StatusOK,
+			expectedBody:       `[{"short_url":"http://test.co/aBcDeF12","original_url":"https://yandex.ru"}]`,
+			expectedNextCursor: "1",
+		},
+		{
+			name:           "Last page",
+			authenticated:  true,
+			query:          "?limit=1&cursor=1",
+			callService:    true,
+			expectedCursor: "1",
+			expectedLimit:  1,
+			mockPage:       URLPage{Records: []URLRecord{{ID: "gHiJkL34", OriginalURL: "https://google.com"}}},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `[{"short_url":"http://test.co/gHiJkL34","original_url":"https://google.com"}]`,
+		},
+		{
+			name:           "Invalid limit",
+			authenticated:  true,
+			query:          "?limit=0",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"limit must be between 1 and 1000"}`,
+		},
+		{
+			name:           "Invalid cursor",
+			authenticated:  true,
+			query:          "?cursor=bad",
+			callService:    true,
+			expectedCursor: "bad",
+			expectedLimit:  defaultUserURLsLimit,
+			mockErr:        ErrInvalidCursor,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"error":"Invalid cursor"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockServicePtr := new(MockShortenerService)
+			handler := NewHandler(mockServicePtr, nil, testBaseURL, logger.Discard())
+
+			if tc.callService {
+				mockServicePtr.On("GetUserURLs", mock.Anything, tc.expectedCursor, tc.expectedLimit).
+					Return(tc.mockPage, tc.mockErr).
+					Once()
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls"+tc.query, nil)
+			if tc.authenticated {
+				req = req.WithContext(auth.WithUserID(req.Context(), "user-1"))
+			} else {
+				req = req.WithContext(auth.WithIssuedUserID(req.Context(), "user-new"))
+			}
+			rr := httptest.NewRecorder()
+
+			handler.GetUserURLs(rr, req)
+
+			result := rr.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tc.expectedStatus, result.StatusCode, "Неверный статус код")
+			assert.Equal(t, tc.expectedNextCursor, result.Header.Get(NextCursorHeader), "Неверный курсор")
+			responseBodyBytes, err := io.ReadAll(result.Body)
+			require.NoError(t, err, "Не удалось прочитать тело ответа")
+			if tc.expectedBody == "" {
+				assert.Empty(t, responseBodyBytes)
+			} else {
+				assert.JSONEq(t, tc.expectedBody, string(responseBodyBytes), "Неверное тело ответа")
+			}
+
+			if tc.callService {
+				mockServicePtr.AssertExpectations(t)
+			} else {
+				mockServicePtr.AssertNotCalled(t, "GetUserURLs", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
 // Тест для хелпера IsValidBase62String
 func TestIsValidBase62String(t *testing.T) {
 	const testLength = 8 // Длина, используемая в приложении
@@ -257,3 +966,84 @@ func TestIsValidBase62String(t *testing.T) {
 		})
 	}
 }
+
+func TestHandler_DeleteUserURLs(t *testing.T) {
+	testCases := []struct {
+		name           string
+		authenticated  bool
+		body           string
+		callService    bool
+		expectedIDs    []string
+		mockErr        error
+		expectedStatus int
+	}{
+		{
+			name:           "Unauthenticated",
+			body:           `["abcdef12"]`,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Accepted",
+			authenticated:  true,
+			body:           `["abcdef12","ABCDEF34"]`,
+			callService:    true,
+			expectedIDs:    []string{"abcdef12", "ABCDEF34"},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name:           "Invalid JSON",
+			authenticated:  true,
+			body:           `{"ids":1}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Empty list",
+			authenticated:  true,
+			body:           `[]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid ID",
+			authenticated:  true,
+			body:           `["abc/def"]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Shutting down",
+			authenticated:  true,
+			body:           `["abcdef12"]`,
+			callService:    true,
+			expectedIDs:    []string{"abcdef12"},
+			mockErr:        ErrShuttingDown,
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockServicePtr := new(MockShortenerService)
+			handler := NewHandler(mockServicePtr, nil, "http://test.co", logger.Discard())
+
+			if tc.callService {
+				mockServicePtr.On("DeleteUserURLs", mock.Anything, tc.expectedIDs).Return(tc.mockErr).Once()
+			}
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", strings.NewReader(tc.body))
+			if tc.authenticated {
+				req = req.WithContext(auth.WithUserID(req.Context(), "user-1"))
+			} else {
+				req = req.WithContext(auth.WithIssuedUserID(req.Context(), "user-new"))
+			}
+			rr := httptest.NewRecorder()
+
+			handler.DeleteUserURLs(rr, req)
+
+			assert.Equal(t, tc.expectedStatus, rr.Code, "Неверный статус код")
+			if tc.callService {
+				mockServicePtr.AssertExpectations(t)
+			} else {
+				mockServicePtr.AssertNotCalled(t, "DeleteUserURLs", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/cmpxNot29a/shurs/internal/helper"
+)
+
+// MaxIDLength - наибольшая длина короткого ID; ограничивает и рост ID при эскалации.
+const MaxIDLength = 32
+
+// IDGenerator создает кандидатов в короткие ID. Уникальность проверяет сервис,
+// резервируя ID в хранилище, и при коллизии запрашивает следующего кандидата.
+// attempt - номер попытки для originalURL, начиная с 0.
+// Format описывает все ID, которые генератор может выдать, для проверки входящих запросов.
+type IDGenerator interface {
+	NewID(ctx context.Context, originalURL string, attempt int) (string, error)
+	Format() IDFormat
+}
+
+// CollisionObserver - необязательная возможность генератора: получать от сервиса
+// результат резервирования каждого кандидата.
+type CollisionObserver interface {
+	ObserveCollision()
+	ObserveSuccess()
+}
+
+// IDFormat - допустимые символы и длины коротких ID.
+type IDFormat struct {
+	Alphabet  string
+	MinLength int
+	MaxLength int
+}
+
+// Match сообщает, может ли id быть выдан генератором с этим форматом.
+func (f IDFormat) Match(id string) bool {
+	if len(id) < f.MinLength || len(id) > f.MaxLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if strings.IndexByte(f.Alphabet, id[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateAlphabet проверяет алфавит коротких ID: от 2 до 256 различных символов,
+// допустимых в пути URL без экранирования (буквы, цифры, "-", ".", "_", "~").
+func ValidateAlphabet(alphabet string) error {
+	if len(alphabet) < 2 || len(alphabet) > 256 {
+		return fmt.Errorf("alphabet must contain from 2 to 256 characters, got %d", len(alphabet))
+	}
+	seen := make(map[byte]struct{}, len(alphabet))
+	for i := 0; i < len(alphabet); i++ {
+		c := alphabet[i]
+		if !isUnreservedURLChar(c) {
+			return fmt.Errorf("alphabet character %q is not allowed in URL paths", c)
+		}
+		if _, dup := seen[c]; dup {
+			return fmt.Errorf("alphabet character %q is repeated", c)
+		}
+		seen[c] = struct{}{}
+	}
+	return nil
+}
+
+// isUnreservedURLChar сообщает, относится ли символ к unreserved по RFC 3986.
+func isUnreservedURLChar(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return true
+	case c == '-', c == '.', c == '_', c == '~':
+		return true
+	}
+	return false
+}
+
+// RandomIDGenerator выдает равномерно случайные ID фиксированной длины из алфавита.
+type RandomIDGenerator struct {
+	alphabet string
+	length   int
+}
+
+// NewRandomIDGenerator создает генератор случайных ID; alphabet проверяется ValidateAlphabet.
+func NewRandomIDGenerator(alphabet string, length int) (*RandomIDGenerator, error) {
+	if err := ValidateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if length <= 0 || length > MaxIDLength {
+		return nil, fmt.Errorf("ID length must be between 1 and %d, got %d", MaxIDLength, length)
+	}
+	return &RandomIDGenerator{alphabet: alphabet, length: length}, nil
+}
+
+// NewID реализует интерфейс IDGenerator.
+func (g *RandomIDGenerator) NewID(ctx context.Context, originalURL string, attempt int) (string, error) {
+	return helper.GenerateRandomString(g.alphabet, g.length)
+}
+
+// Format реализует интерфейс IDGenerator.
+func (g *RandomIDGenerator) Format() IDFormat {
+	return IDFormat{Alphabet: g.alphabet, MinLength: g.length, MaxLength: g.length}
+}
+
+// EscalatingIDGenerator выдает случайные ID и удлиняет их на один символ после
+// threshold коллизий подряд: частые коллизии означают, что пространство ID текущей
+// длины почти заполнено. Длина растет не дальше MaxIDLength и не уменьшается.
+type EscalatingIDGenerator struct {
+	alphabet    string
+	minLength   int
+	threshold   int
+	mu          sync.Mutex
+	length      int
+	consecutive int
+	logger      *slog.Logger
+}
+
+// NewEscalatingIDGenerator создает генератор с начальной длиной length.
+func NewEscalatingIDGenerator(alphabet string, length, threshold int, logger *slog.Logger) (*EscalatingIDGenerator, error) {
+	if _, err := NewRandomIDGenerator(alphabet, length); err != nil {
+		return nil, err
+	}
+	if threshold <= 0 {
+		return nil, fmt.Errorf("escalation threshold must be positive, got %d", threshold)
+	}
+	return &EscalatingIDGenerator{
+		alphabet:  alphabet,
+		minLength: length,
+		threshold: threshold,
+		length:    length,
+		logger:    logger.With("component", "idgen"),
+	}, nil
+}
+
+// NewID реализует интерфейс IDGenerator.
+func (g *EscalatingIDGenerator) NewID(ctx context.Context, originalURL string, attempt int) (string, error) {
+	return helper.GenerateRandomString(g.alphabet, g.Length())
+}
+
+// Format реализует интерфейс IDGenerator. Допускаются все длины, до которых генератор
+// может дорасти: ранее выданные короткие ID остаются действительными.
+func (g *EscalatingIDGenerator) Format() IDFormat {
+	return IDFormat{Alphabet: g.alphabet, MinLength: g.minLength, MaxLength: MaxIDLength}
+}
+
+// Length возвращает текущую длину выдаваемых ID.
+func (g *EscalatingIDGenerator) Length() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.length
+}
+
+// ObserveCollision реализует интерфейс CollisionObserver.
+func (g *EscalatingIDGenerator) ObserveCollision() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.consecutive++
+	if g.consecutive < g.threshold || g.length >= MaxIDLength {
+		return
+	}
+	g.consecutive = 0
+	g.length++
+	g.logger.Warn("Too many consecutive ID collisions, growing ID length",
+		"threshold", g.threshold, "id_length", g.length)
+}
+
+// ObserveSuccess реализует интерфейс CollisionObserver.
+func (g *EscalatingIDGenerator) ObserveSuccess() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.consecutive = 0
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// DeterministicIDGenerator - необязательная возможность генератора: ID зависит только
+// от URL и номера попытки. Тогда сервис узнает уже сокращенный URL по самому ID,
+// не обращаясь к поиску по оригинальному URL.
+type DeterministicIDGenerator interface {
+	IDGenerator
+	Deterministic() bool
+}
+
+// HashIDGenerator выдает ID из HMAC-SHA256 нормализованного URL: один и тот же URL
+// на любом экземпляре с тем же ключом получает один и тот же ID. При коллизии
+// номер попытки добавляется к хешу как соль, так что цепочка кандидатов для URL
+// тоже детерминирована.
+type HashIDGenerator struct {
+	key      []byte
+	alphabet string
+	length   int
+}
+
+// NewHashIDGenerator создает генератор ID длины length из alphabet.
+// key должен совпадать на всех экземплярах и не меняться, иначе один URL получит разные ID.
+func NewHashIDGenerator(alphabet string, length int, key string) (*HashIDGenerator, error) {
+	if err := ValidateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if length <= 0 || length > MaxIDLength {
+		return nil, fmt.Errorf("ID length must be between 1 and %d, got %d", MaxIDLength, length)
+	}
+	if key == "" {
+		return nil, errors.New("ID key must not be empty")
+	}
+	return &HashIDGenerator{key: []byte(key), alphabet: alphabet, length: length}, nil
+}
+
+// NewID реализует интерфейс IDGenerator.
+func (g *HashIDGenerator) NewID(ctx context.Context, originalURL string, attempt int) (string, error) {
+	var salt [8]byte
+	binary.BigEndian.PutUint64(salt[:], uint64(attempt))
+	mac := hmac.New(sha256.New, g.key)
+	mac.Write(salt[:])
+	mac.Write([]byte(normalizeURL(originalURL)))
+	return g.encode(mac.Sum(nil)), nil
+}
+
+// Format реализует интерфейс IDGenerator.
+func (g *HashIDGenerator) Format() IDFormat {
+	return IDFormat{Alphabet: g.alphabet, MinLength: g.length, MaxLength: g.length}
+}
+
+// Deterministic реализует интерфейс DeterministicIDGenerator.
+func (g *HashIDGenerator) Deterministic() bool {
+	return true
+}
+
+// encode берет младшие length разрядов хеша в системе счисления алфавита.
+// 256 бит хеша с большим запасом перекрывают len(alphabet)^length при разумных длинах,
+// поэтому смещение от взятия по модулю пренебрежимо мало.
+func (g *HashIDGenerator) encode(sum []byte) string {
+	x := new(big.Int).SetBytes(sum)
+	base := big.NewInt(int64(len(g.alphabet)))
+	digit := new(big.Int)
+	buf := make([]byte, g.length)
+	for i := g.length - 1; i >= 0; i-- {
+		x.QuoRem(x, base, digit)
+		buf[i] = g.alphabet[digit.Int64()]
+	}
+	return string(buf)
+}
+
+// normalizeURL приводит URL к виду, не зависящему от несущественных различий записи:
+// схема и хост в нижнем регистре, без порта по умолчанию, пустой путь заменяется на "/".
+// Если URL не разбирается, он используется как есть.
+func normalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
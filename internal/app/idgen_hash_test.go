@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cmpxNot29a/shurs/internal/helper"
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashIDGenerator(t *testing.T) {
+	ctx := context.Background()
+	g, err := NewHashIDGenerator(helper.Base62Alphabet, 8, "secret")
+	require.NoError(t, err)
+	// Второй экземпляр с тем же ключом имитирует независимый сервер.
+	other, err := NewHashIDGenerator(helper.Base62Alphabet, 8, "secret")
+	require.NoError(t, err)
+
+	id, err := g.NewID(ctx, "https://yandex.ru/search", 0)
+	require.NoError(t, err)
+	assert.True(t, g.Format().Match(id))
+
+	same, err := other.NewID(ctx, "https://yandex.ru/search", 0)
+	require.NoError(t, err)
+	assert.Equal(t, id, same, "один URL должен давать один ID на любом экземпляре")
+
+	salted, err := g.NewID(ctx, "https://yandex.ru/search", 1)
+	require.NoError(t, err)
+	assert.NotEqual(t, id, salted, "номер попытки должен менять ID")
+
+	rekeyed, err := NewHashIDGenerator(helper.Base62Alphabet, 8, "another")
+	require.NoError(t, err)
+	foreign, err := rekeyed.NewID(ctx, "https://yandex.ru/search", 0)
+	require.NoError(t, err)
+	assert.NotEqual(t, id, foreign, "другой ключ должен давать другой ID")
+
+	_, err = NewHashIDGenerator(helper.Base62Alphabet, 8, "")
+	assert.Error(t, err)
+}
+
+func TestNormalizeURL(t *testing.T) {
+	testCases := []struct {
+		rawURL string
+		want   string
+	}{
+		{rawURL: "HTTPS://Yandex.RU", want: "https://yandex.ru/"},
+		{rawURL: "https://yandex.ru:443/Search?q=Go", want: "https://yandex.ru/Search?q=Go"},
+		{rawURL: "http://yandex.ru:80/", want: "http://yandex.ru/"},
+		{rawURL: "http://yandex.ru:8080/", want: "http://yandex.ru:8080/"},
+		{rawURL: "http://[::1]:80/a", want: "http://[::1]/a"},
+		{rawURL: "not a url", want: "not a url"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.rawURL, func(t *testing.T) {
+			assert.Equal(t, tc.want, normalizeURL(tc.rawURL))
+		})
+	}
+}
+
+// noReverseLookupStorage запрещает поиск по оригинальному URL.
+type noReverseLookupStorage struct {
+	*InMemoryStorage
+}
+
+func (s noReverseLookupStorage) GetByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+	return "", errors.New("reverse lookup is not expected")
+}
+
+func TestShortenerService_HashIDsAreIdempotent(t *testing.T) {
+	ctx := context.Background()
+	g, err := NewHashIDGenerator(helper.Base62Alphabet, 8, "secret")
+	require.NoError(t, err)
+	storage := NewInMemoryStorage()
+
+	// Первый кандидат URL уже занят другой ссылкой, второй - удаленной.
+	first, err := g.NewID(ctx, "https://yandex.ru", 0)
+	require.NoError(t, err)
+	second, err := g.NewID(ctx, "https://yandex.ru", 1)
+	require.NoError(t, err)
+	require.NoError(t, storage.Save(ctx, URLRecord{ID: first, OriginalURL: "https://google.com"}))
+	require.NoError(t, storage.Save(ctx, URLRecord{ID: second, OriginalURL: "https://ya.ru", UserID: "user"}))
+	require.NoError(t, storage.DeleteByUser(ctx, "user", []string{second}))
+
+	service := NewShortenerService(noReverseLookupStorage{storage}, g, 5, nil, nil, nil, logger.Discard())
+	id, err := service.CreateShortURL(ctx, "https://yandex.ru")
+	require.NoError(t, err)
+	third, err := g.NewID(ctx, "https://yandex.ru", 2)
+	require.NoError(t, err)
+	assert.Equal(t, third, id, "после коллизий ID берется из следующей попытки")
+
+	again, err := service.CreateShortURL(ctx, "https://yandex.ru")
+	assert.ErrorIs(t, err, ErrURLExists)
+	assert.Equal(t, id, again, "повторное сокращение должно вернуть тот же ID")
+
+	ids, err := service.CreateShortURLBatch(ctx, []string{"https://yandex.ru", "https://go.dev"})
+	require.NoError(t, err)
+	single, err := g.NewID(ctx, "https://go.dev", 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{id, single}, ids, "пачка должна давать те же ID, что и одиночное сокращение")
+}
+
+func TestShortenerService_HashIDsIgnoreURLSpelling(t *testing.T) {
+	ctx := context.Background()
+	g, err := NewHashIDGenerator(helper.Base62Alphabet, 8, "secret")
+	require.NoError(t, err)
+	service := NewShortenerService(noReverseLookupStorage{NewInMemoryStorage()}, g, 5, nil, nil, nil, logger.Discard())
+
+	id, err := service.CreateShortURL(ctx, "HTTPS://Example.com")
+	require.NoError(t, err)
+
+	again, err := service.CreateShortURL(ctx, "https://example.com")
+	assert.ErrorIs(t, err, ErrURLExists, "другая запись того же URL - повторное сокращение, а не коллизия")
+	assert.Equal(t, id, again)
+}
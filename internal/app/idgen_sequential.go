@@ -0,0 +1,205 @@
+package app
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+	"sync"
+)
+
+const (
+	// feistelRounds - число раундов сети Фейстеля; 8 с запасом перекрывает минимально
+	// необходимые 4 для неотличимости от случайной перестановки.
+	feistelRounds = 8
+	// maxSequentialBits - наибольшая разрядность пространства ID последовательного генератора.
+	maxSequentialBits = 62
+)
+
+// ErrIDSpaceExhausted - счетчик вышел за пределы пространства ID заданной длины.
+var ErrIDSpaceExhausted = errors.New("short ID space exhausted")
+
+// SequentialIDGenerator выдает ID по монотонному счетчику из хранилища. Значения
+// берутся блоками по blockSize, чтобы не обращаться к хранилищу на каждый запрос,
+// и пропускаются через ключевую перестановку пространства ID: соседние значения
+// счетчика дают непохожие ID, угадать следующий без ключа нельзя, а коллизий нет.
+// Значения неиспользованного остатка блока при перезапуске теряются.
+type SequentialIDGenerator struct {
+	allocator SequenceAllocator
+	alphabet  string
+	length    int
+	blockSize int64
+	perm      *feistelPermutation
+	mu        sync.Mutex
+	next, end int64 // текущий блок [next, end)
+}
+
+// NewSequentialIDGenerator создает генератор ID длины length из alphabet.
+// Пространство len(alphabet)^length не должно превышать 2^62; key задает перестановку
+// и должен быть постоянным, иначе после смены ключа новые ID могут совпасть со старыми.
+func NewSequentialIDGenerator(allocator SequenceAllocator, alphabet string, length int, blockSize int64, key string) (*SequentialIDGenerator, error) {
+	if err := ValidateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if length <= 0 || length > MaxIDLength {
+		return nil, fmt.Errorf("ID length must be between 1 and %d, got %d", MaxIDLength, length)
+	}
+	if blockSize <= 0 {
+		return nil, fmt.Errorf("ID block size must be positive, got %d", blockSize)
+	}
+	if key == "" {
+		return nil, errors.New("ID key must not be empty")
+	}
+	domain, err := idSpaceSize(len(alphabet), length)
+	if err != nil {
+		return nil, err
+	}
+	return &SequentialIDGenerator{
+		allocator: allocator,
+		alphabet:  alphabet,
+		length:    length,
+		blockSize: blockSize,
+		perm:      newFeistelPermutation([]byte(key), domain),
+	}, nil
+}
+
+// idSpaceSize возвращает base^length, если оно не превышает 2^maxSequentialBits.
+func idSpaceSize(base, length int) (uint64, error) {
+	size := uint64(1)
+	for range length {
+		hi, lo := bits.Mul64(size, uint64(base))
+		if hi != 0 || lo > 1<<maxSequentialBits {
+			return 0, fmt.Errorf("ID space %d^%d is too large for sequential IDs, max 2^%d",
+				base, length, maxSequentialBits)
+		}
+		size = lo
+	}
+	return size, nil
+}
+
+// NewID реализует интерфейс IDGenerator. originalURL и attempt не используются:
+// каждый вызов берет следующее значение счетчика.
+func (g *SequentialIDGenerator) NewID(ctx context.Context, originalURL string, attempt int) (string, error) {
+	value, err := g.nextValue(ctx)
+	if err != nil {
+		return "", err
+	}
+	if uint64(value) >= g.perm.domain {
+		return "", ErrIDSpaceExhausted
+	}
+	return g.encode(g.perm.encrypt(uint64(value))), nil
+}
+
+// Format реализует интерфейс IDGenerator.
+func (g *SequentialIDGenerator) Format() IDFormat {
+	return IDFormat{Alphabet: g.alphabet, MinLength: g.length, MaxLength: g.length}
+}
+
+// Decode возвращает значение счетчика, из которого получен id.
+func (g *SequentialIDGenerator) Decode(id string) (int64, error) {
+	if !g.Format().Match(id) {
+		return 0, fmt.Errorf("ID %q does not match generator format", id)
+	}
+	var x uint64
+	base := uint64(len(g.alphabet))
+	for i := 0; i < len(id); i++ {
+		x = x*base + uint64(strings.IndexByte(g.alphabet, id[i]))
+	}
+	if x >= g.perm.domain {
+		return 0, fmt.Errorf("ID %q is out of generator space", id)
+	}
+	return int64(g.perm.decrypt(x)), nil
+}
+
+// nextValue выдает следующее значение счетчика, при необходимости выделяя новый блок.
+func (g *SequentialIDGenerator) nextValue(ctx context.Context) (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.next == g.end {
+		start, err := g.allocator.AllocateIDBlock(ctx, g.blockSize)
+		if err != nil {
+			return 0, fmt.Errorf("failed to allocate ID block: %w", err)
+		}
+		g.next, g.end = start, start+g.blockSize
+	}
+	value := g.next
+	g.next++
+	return value, nil
+}
+
+// encode записывает x в системе счисления алфавита, дополняя слева до длины ID.
+func (g *SequentialIDGenerator) encode(x uint64) string {
+	base := uint64(len(g.alphabet))
+	buf := make([]byte, g.length)
+	for i := g.length - 1; i >= 0; i-- {
+		buf[i] = g.alphabet[x%base]
+		x /= base
+	}
+	return string(buf)
+}
+
+// feistelPermutation - ключевая биективная перестановка чисел [0, domain).
+// Сбалансированная сеть Фейстеля переставляет числа из ceil(log2(domain)) бит
+// (округленного до четного); результат вне domain шифруется повторно (cycle walking),
+// пока не попадет в domain.
+type feistelPermutation struct {
+	key      []byte
+	domain   uint64
+	halfBits uint
+	mask     uint64
+}
+
+func newFeistelPermutation(key []byte, domain uint64) *feistelPermutation {
+	n := uint(bits.Len64(domain - 1))
+	n += n % 2
+	if n < 2 {
+		n = 2
+	}
+	return &feistelPermutation{
+		key:      key,
+		domain:   domain,
+		halfBits: n / 2,
+		mask:     1<<(n/2) - 1,
+	}
+}
+
+func (p *feistelPermutation) encrypt(x uint64) uint64 {
+	for {
+		l, r := x>>p.halfBits, x&p.mask
+		for i := range feistelRounds {
+			l, r = r, l^p.round(i, r)
+		}
+		x = l<<p.halfBits | r
+		if x < p.domain {
+			return x
+		}
+	}
+}
+
+func (p *feistelPermutation) decrypt(x uint64) uint64 {
+	for {
+		l, r := x>>p.halfBits, x&p.mask
+		for i := feistelRounds - 1; i >= 0; i-- {
+			l, r = r^p.round(i, l), l
+		}
+		x = l<<p.halfBits | r
+		if x < p.domain {
+			return x
+		}
+	}
+}
+
+// round - раундовая функция: HMAC-SHA256 от номера раунда и половины блока.
+func (p *feistelPermutation) round(i int, half uint64) uint64 {
+	var msg [9]byte
+	msg[0] = byte(i)
+	binary.BigEndian.PutUint64(msg[1:], half)
+	mac := hmac.New(sha256.New, p.key)
+	mac.Write(msg[:])
+	return binary.BigEndian.Uint64(mac.Sum(nil)) & p.mask
+}
@@ -0,0 +1,178 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/cmpxNot29a/shurs/internal/helper"
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeistelPermutation_IsBijective(t *testing.T) {
+	for _, domain := range []uint64{2, 16, 62 * 62, 1000} {
+		perm := newFeistelPermutation([]byte("secret"), domain)
+		seen := make(map[uint64]struct{}, domain)
+		for x := range domain {
+			y := perm.encrypt(x)
+			require.Less(t, y, domain, "значение вне пространства")
+			seen[y] = struct{}{}
+			assert.Equal(t, x, perm.decrypt(y), "перестановка должна обращаться")
+		}
+		assert.Len(t, seen, int(domain), "перестановка должна быть биекцией")
+	}
+}
+
+// countingAllocator считает обращения к хранилищу за блоками.
+type countingAllocator struct {
+	SequenceAllocator
+	mu    sync.Mutex
+	calls int
+}
+
+func (a *countingAllocator) AllocateIDBlock(ctx context.Context, size int64) (int64, error) {
+	a.mu.Lock()
+	a.calls++
+	a.mu.Unlock()
+	return a.SequenceAllocator.AllocateIDBlock(ctx, size)
+}
+
+func TestSequentialIDGenerator(t *testing.T) {
+	ctx := context.Background()
+	allocator := &countingAllocator{SequenceAllocator: NewInMemoryStorage()}
+	g, err := NewSequentialIDGenerator(allocator, helper.Base62Alphabet, 8, 100, "secret")
+	require.NoError(t, err)
+
+	const total = 1000
+	ids := make(map[string]struct{}, total)
+	for i := range total {
+		id, err := g.NewID(ctx, "https://yandex.ru", 0)
+		require.NoError(t, err)
+		require.True(t, g.Format().Match(id))
+		ids[id] = struct{}{}
+
+		value, err := g.Decode(id)
+		require.NoError(t, err)
+		assert.EqualValues(t, i, value, "ID должен обращаться в значение счетчика")
+	}
+	assert.Len(t, ids, total, "последовательные ID не должны повторяться")
+	assert.Equal(t, total/100, allocator.calls, "блоки должны выделяться по blockSize значений")
+
+	other, err := NewSequentialIDGenerator(NewInMemoryStorage(), helper.Base62Alphabet, 8, 100, "another")
+	require.NoError(t, err)
+	first, err := other.NewID(ctx, "https://yandex.ru", 0)
+	require.NoError(t, err)
+	assert.NotEqual(t, g.encode(g.perm.encrypt(0)), first, "другой ключ должен давать другую перестановку")
+}
+
+func TestSequentialIDGenerator_Exhausted(t *testing.T) {
+	g, err := NewSequentialIDGenerator(NewInMemoryStorage(), "ab", 2, 10, "secret")
+	require.NoError(t, err)
+
+	for range 4 {
+		_, err := g.NewID(context.Background(), "https://yandex.ru", 0)
+		require.NoError(t, err)
+	}
+	_, err = g.NewID(context.Background(), "https://yandex.ru", 0)
+	assert.ErrorIs(t, err, ErrIDSpaceExhausted)
+}
+
+func TestNewSequentialIDGenerator_Validation(t *testing.T) {
+	storage := NewInMemoryStorage()
+	_, err := NewSequentialIDGenerator(storage, helper.Base62Alphabet, 11, 100, "secret")
+	assert.Error(t, err, "62^11 не помещается в 62 бита")
+	_, err = NewSequentialIDGenerator(storage, helper.Base62Alphabet, 8, 0, "secret")
+	assert.Error(t, err)
+	_, err = NewSequentialIDGenerator(storage, helper.Base62Alphabet, 8, 100, "")
+	assert.Error(t, err)
+}
+
+func TestShortenerService_SequentialIDs(t *testing.T) {
+	storage := NewInMemoryStorage()
+	g, err := NewSequentialIDGenerator(storage, helper.Base62Alphabet, 8, 10, "secret")
+	require.NoError(t, err)
+	service := NewShortenerService(storage, g, 1, nil, nil, nil, logger.Discard())
+
+	id, err := service.CreateShortURL(context.Background(), "https://yandex.ru")
+	require.NoError(t, err)
+	value, err := g.Decode(id)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, value)
+}
+
+// testAllocateIDBlock проверяет, что блоки счетчика не пересекаются при конкурентном выделении.
+func testAllocateIDBlock(t *testing.T, allocator SequenceAllocator) {
+	t.Helper()
+	ctx := context.Background()
+
+	const (
+		workers = 8
+		blocks  = 20
+		size    = 10
+	)
+	var (
+		mu     sync.Mutex
+		starts = make(map[int64]struct{}, workers*blocks)
+		wg     sync.WaitGroup
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range blocks {
+				start, err := allocator.AllocateIDBlock(ctx, size)
+				if !assert.NoError(t, err) {
+					return
+				}
+				mu.Lock()
+				starts[start] = struct{}{}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, starts, workers*blocks)
+	for start := range starts {
+		assert.Zero(t, start%size, "блоки должны идти подряд без перекрытий")
+		assert.Less(t, start, int64(workers*blocks*size))
+	}
+}
+
+func TestInMemoryStorage_AllocateIDBlock(t *testing.T) {
+	testAllocateIDBlock(t, NewInMemoryStorage())
+}
+
+func TestSQLiteStorage_AllocateIDBlock(t *testing.T) {
+	testAllocateIDBlock(t, newTestSQLiteStorage(t))
+}
+
+func TestPostgresStorage_AllocateIDBlock(t *testing.T) {
+	testAllocateIDBlock(t, newTestPostgresStorage(t))
+}
+
+func TestFileStorage_AllocateIDBlockSurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	s, err := NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+	testAllocateIDBlock(t, s)
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "abcdef12", OriginalURL: "https://yandex.ru"}))
+	require.NoError(t, s.Close())
+
+	s, err = NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+	defer s.Close()
+
+	start, err := s.AllocateIDBlock(ctx, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 8*20*10, start, "счетчик должен продолжиться после перезапуска")
+
+	url, err := s.GetByID(ctx, "abcdef12")
+	require.NoError(t, err)
+	assert.Equal(t, "https://yandex.ru", url)
+}
@@ -0,0 +1,160 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/cmpxNot29a/shurs/internal/helper"
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestIDGenerator возвращает генератор случайных base62 ID длины 8.
+func newTestIDGenerator(t *testing.T) IDGenerator {
+	t.Helper()
+	g, err := NewRandomIDGenerator(helper.Base62Alphabet, 8)
+	require.NoError(t, err)
+	return g
+}
+
+// chiSquare считает статистику хи-квадрат для частот символов при равномерном распределении.
+func chiSquare(counts map[byte]int, alphabet string) float64 {
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	expected := float64(total) / float64(len(alphabet))
+	var stat float64
+	for i := 0; i < len(alphabet); i++ {
+		d := float64(counts[alphabet[i]]) - expected
+		stat += d * d / expected
+	}
+	return stat
+}
+
+// Пороги хи-квадрат выбраны с большим запасом над квантилем 0.99999, чтобы тест
+// практически не падал случайно, но надежно ловил смещение b % len(alphabet).
+func TestRandomIDGenerator_Uniformity(t *testing.T) {
+	testCases := []struct {
+		name      string
+		alphabet  string
+		length    int
+		samples   int
+		threshold float64
+	}{
+		{name: "base62", alphabet: helper.Base62Alphabet, length: 8, samples: 8000, threshold: 125},
+		{name: "custom alphabet", alphabet: "abc-_", length: 10, samples: 2000, threshold: 30},
+		{name: "all unreserved characters", alphabet: helper.Base62Alphabet + "-._~", length: 6, samples: 10000, threshold: 130},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g, err := NewRandomIDGenerator(tc.alphabet, tc.length)
+			require.NoError(t, err)
+
+			counts := make(map[byte]int, len(tc.alphabet))
+			for range tc.samples {
+				id, err := g.NewID(context.Background(), "https://yandex.ru", 0)
+				require.NoError(t, err)
+				require.Len(t, id, tc.length)
+				require.True(t, g.Format().Match(id), "ID %q не соответствует формату", id)
+				for i := 0; i < len(id); i++ {
+					counts[id[i]]++
+				}
+			}
+			assert.Less(t, chiSquare(counts, tc.alphabet), tc.threshold, "распределение символов неравномерно")
+		})
+	}
+}
+
+// Проверка, что тест равномерности действительно замечает смещение старого способа b % 62.
+func TestChiSquare_DetectsModuloBias(t *testing.T) {
+	alphabet := helper.Base62Alphabet
+	buf := make([]byte, 64000)
+	_, err := rand.Read(buf)
+	require.NoError(t, err)
+
+	counts := make(map[byte]int, len(alphabet))
+	for _, b := range buf {
+		counts[alphabet[int(b)%len(alphabet)]]++
+	}
+	assert.Greater(t, chiSquare(counts, alphabet), float64(125))
+}
+
+func TestEscalatingIDGenerator(t *testing.T) {
+	g, err := NewEscalatingIDGenerator(helper.Base62Alphabet, 4, 3, logger.Discard())
+	require.NoError(t, err)
+
+	// Успех обнуляет счетчик коллизий подряд.
+	g.ObserveCollision()
+	g.ObserveCollision()
+	g.ObserveSuccess()
+	g.ObserveCollision()
+	g.ObserveCollision()
+	assert.Equal(t, 4, g.Length())
+
+	g.ObserveCollision()
+	assert.Equal(t, 5, g.Length(), "после 3 коллизий подряд ID должен удлиниться")
+
+	id, err := g.NewID(context.Background(), "https://yandex.ru", 0)
+	require.NoError(t, err)
+	assert.Len(t, id, 5)
+
+	format := g.Format()
+	assert.True(t, format.Match("abcd"), "ранее выданные короткие ID остаются допустимыми")
+	assert.True(t, format.Match("abcde"))
+	assert.False(t, format.Match("abc"))
+
+	for range 3 * MaxIDLength {
+		g.ObserveCollision()
+	}
+	assert.Equal(t, MaxIDLength, g.Length(), "длина не растет дальше MaxIDLength")
+}
+
+func TestShortenerService_EscalatesIDLength(t *testing.T) {
+	storage := NewInMemoryStorage()
+	g, err := NewEscalatingIDGenerator("ab", 1, 2, logger.Discard())
+	require.NoError(t, err)
+	service := NewShortenerService(storage, g, 10, nil, nil, nil, logger.Discard())
+
+	// Алфавит из двух символов и длина 1: после двух ссылок все ID длины 1 заняты,
+	// и следующие коллизии заставляют генератор удлинить ID.
+	for _, url := range []string{"https://a.ru", "https://b.ru", "https://c.ru"} {
+		id, err := service.CreateShortURL(context.Background(), url)
+		require.NoError(t, err)
+		got, err := storage.GetByID(context.Background(), id)
+		require.NoError(t, err)
+		assert.Equal(t, url, got)
+	}
+	assert.Greater(t, g.Length(), 1)
+}
+
+func TestValidateAlphabet(t *testing.T) {
+	testCases := []struct {
+		alphabet string
+		valid    bool
+	}{
+		{alphabet: helper.Base62Alphabet, valid: true},
+		{alphabet: "ab-_.~", valid: true},
+		{alphabet: "a", valid: false},
+		{alphabet: "abca", valid: false},
+		{alphabet: "ab/", valid: false},
+		{alphabet: "ab?", valid: false},
+		{alphabet: "абв", valid: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.alphabet, func(t *testing.T) {
+			err := ValidateAlphabet(tc.alphabet)
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+
+	_, err := NewRandomIDGenerator(helper.Base62Alphabet, MaxIDLength+1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,215 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cmpxNot29a/shurs/internal/metrics"
+)
+
+const (
+	// countLinksTimeout ограничивает подсчет ссылок для метрики.
+	countLinksTimeout = 5 * time.Second
+	// linksRefreshInterval - как долго метрика отдает ранее подсчитанное число ссылок:
+	// подсчет в базе данных просматривает всю таблицу и не должен идти на каждый сбор.
+	linksRefreshInterval = time.Minute
+)
+
+// Metrics - метрики сервиса. Все методы безопасны для nil: без метрик сервис работает как прежде.
+type Metrics struct {
+	httpRequests     *metrics.Counter
+	httpDuration     *metrics.Histogram
+	operations       *metrics.Histogram
+	idCollisions     *metrics.Counter
+	idRetries        *metrics.Counter
+	storageErrors    *metrics.Counter
+	clicksDropped    *metrics.Counter
+	clicksUnresolved *metrics.Counter
+	storageBackend   string
+}
+
+// NewMetrics регистрирует метрики сервиса в реестре. Число ссылок берется из хранилища,
+// если оно поддерживает LinkCounter, и обновляется не чаще linksRefreshInterval (см.
+// linkGauge); запрос идет через декоратор метрик, так что ошибки подсчета тоже учитываются.
+func NewMetrics(reg *metrics.Registry, storage Storage, logger *slog.Logger) *Metrics {
+	log := logger.With("component", "metrics")
+	m := &Metrics{
+		httpRequests: reg.NewCounter("shortener_http_requests_total",
+			"HTTP requests by method, route pattern and status code.", "method", "route", "status"),
+		httpDuration: reg.NewHistogram("shortener_http_request_duration_seconds",
+			"HTTP request latency by method and route pattern.", metrics.DefBuckets, "method", "route"),
+		operations: reg.NewHistogram("shortener_operation_duration_seconds",
+			"Latency of shortener use case operations.", metrics.DefBuckets, "operation", "result"),
+		idCollisions: reg.NewCounter("shortener_id_collisions_total",
+			"Generated short IDs that were already taken."),
+		idRetries: reg.NewCounter("shortener_id_generation_retries_total",
+			"Additional attempts made to generate a unique short ID."),
+		storageErrors: reg.NewCounter("shortener_storage_errors_total",
+			"Unexpected storage errors by backend and operation.", "backend", "operation"),
+		clicksDropped: reg.NewCounter("shortener_clicks_dropped_total",
+			"Click events dropped because the analytics queue was full."),
+		clicksUnresolved: reg.NewCounter("shortener_clicks_unresolved_total",
+			"Click events dropped because their link could not be resolved."),
+		storageBackend: storageBackend(storage),
+	}
+	links := func() float64 { return math.NaN() }
+	if counter, ok := storageCapability[LinkCounter](InstrumentStorage(storage, m)); ok {
+		links = newLinkGauge(counter, log).value
+	}
+	reg.NewGaugeFunc("shortener_links", "Short links currently stored and not deleted.", links)
+	return m
+}
+
+// linkGauge отдает число ссылок для метрики из кеша. Первый сбор считает ссылки сразу,
+// следующие отдают последнее значение и, если оно старше linksRefreshInterval, запускают
+// пересчет в фоне; одновременно идет не больше одного пересчета.
+type linkGauge struct {
+	counter    LinkCounter
+	mu         sync.Mutex
+	links      float64
+	updated    time.Time // момент последнего пересчета; нулевой - пересчетов еще не было
+	refreshing bool
+	logger     *slog.Logger
+}
+
+func newLinkGauge(counter LinkCounter, logger *slog.Logger) *linkGauge {
+	return &linkGauge{counter: counter, links: math.NaN(), logger: logger}
+}
+
+// value возвращает число ссылок; NaN, если подсчитать его еще не удалось.
+func (g *linkGauge) value() float64 {
+	g.mu.Lock()
+	first := g.updated.IsZero() && !g.refreshing
+	stale := !g.refreshing && time.Since(g.updated) >= linksRefreshInterval
+	if stale {
+		g.refreshing = true
+	}
+	g.mu.Unlock()
+
+	switch {
+	case first:
+		g.refresh()
+	case stale:
+		go g.refresh()
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.links
+}
+
+// refresh пересчитывает ссылки; при ошибке остается прежнее значение.
+func (g *linkGauge) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), countLinksTimeout)
+	defer cancel()
+	n, err := g.counter.CountLinks(ctx)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.refreshing = false
+	g.updated = time.Now()
+	if err != nil {
+		g.logger.Warn("Failed to count links", "error", err)
+		return
+	}
+	g.links = float64(n)
+}
+
+// storageBackend возвращает имя реализации хранилища для метки backend.
+// Декораторы пропускаются.
+func storageBackend(storage Storage) string {
+	if wrapper, ok := storage.(interface{ Unwrap() Storage }); ok {
+		return storageBackend(wrapper.Unwrap())
+	}
+	switch storage.(type) {
+	case *InMemoryStorage:
+		return "memory"
+	case *FileStorage:
+		return "file"
+	case *PostgresStorage:
+		return "postgres"
+	case *SQLiteStorage:
+		return "sqlite"
+	}
+	return "unknown"
+}
+
+func (m *Metrics) observeRequest(method, route string, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.httpRequests.Inc(method, route, statusLabel(status))
+	m.httpDuration.Observe(duration.Seconds(), method, route)
+}
+
+func (m *Metrics) observeOperation(operation string, err error, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	result := "ok"
+	if err != nil && !isExpectedStorageError(err) {
+		result = "error"
+	}
+	m.operations.Observe(duration.Seconds(), operation, result)
+}
+
+func (m *Metrics) idCollision() {
+	if m == nil {
+		return
+	}
+	m.idCollisions.Inc()
+}
+
+func (m *Metrics) idRetry() {
+	if m == nil {
+		return
+	}
+	m.idRetries.Inc()
+}
+
+func (m *Metrics) clickDropped() {
+	if m == nil {
+		return
+	}
+	m.clicksDropped.Inc()
+}
+
+func (m *Metrics) clickUnresolved() {
+	if m == nil {
+		return
+	}
+	m.clicksUnresolved.Inc()
+}
+
+// storageError учитывает ошибку операции хранилища; ожидаемые ответы (не найдено,
+// коллизия, URL уже сокращен) ошибками не считаются.
+func (m *Metrics) storageError(operation string, err error) {
+	if m == nil || err == nil || isExpectedStorageError(err) {
+		return
+	}
+	m.storageErrors.Inc(m.storageBackend, operation)
+}
+
+// isExpectedStorageError сообщает, является ли err штатным ответом хранилища.
+func isExpectedStorageError(err error) bool {
+	for _, expected := range []error{ErrNotFound, ErrConflict, ErrURLExists, ErrDeleted, ErrInvalidCursor, ErrInvalidAlias, ErrExpired, ErrClicksExhausted,
+		ErrPasswordRequired, ErrInvalidPassword, ErrWrongPassword, ErrTooManyAttempts} {
+		if errors.Is(err, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+func statusLabel(status int) string {
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return strconv.Itoa(status)
+}
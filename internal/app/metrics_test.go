@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/cmpxNot29a/shurs/internal/metrics"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstrumentStorage_KeepsCapabilities(t *testing.T) {
+	memory := InstrumentStorage(NewInMemoryStorage(), NewMetrics(metrics.NewRegistry(), NewInMemoryStorage(), logger.Discard()))
+	_, isBatch := storageCapability[BatchSaver](memory)
+	assert.False(t, isBatch, "у хранилища в памяти нет BatchSaver")
+	_, isPinger := storageCapability[Pinger](memory)
+	assert.False(t, isPinger, "у хранилища в памяти нет Pinger")
+	_, isAbsent := memory.(AbsentSaver)
+	assert.True(t, isAbsent)
+
+	sqlite := newTestSQLiteStorage(t)
+	wrapped := InstrumentStorage(sqlite, NewMetrics(metrics.NewRegistry(), sqlite, logger.Discard()))
+	_, isBatch = storageCapability[BatchSaver](wrapped)
+	assert.True(t, isBatch, "BatchSaver SQLite должен сохраниться")
+	_, isPinger = storageCapability[Pinger](wrapped)
+	assert.True(t, isPinger)
+	_, isCounter := storageCapability[LinkCounter](wrapped)
+	assert.True(t, isCounter)
+	_, isAllocator := storageCapability[SequenceAllocator](wrapped)
+	assert.True(t, isAllocator)
+	purger, isPurger := storageCapability[ExpiredPurger](wrapped)
+	require.True(t, isPurger)
+	assert.Same(t, wrapped, purger, "вызовы возможностей должны идти через декоратор")
+	assert.Equal(t, "sqlite", storageBackend(wrapped))
+
+	_, isCounter = storageCapability[LinkCounter](InstrumentStorage(new(MockStorage), nil))
+	assert.False(t, isCounter, "декоратор не добавляет возможностей, которых нет у хранилища")
+}
+
+func TestInstrumentStorage_CountsUnexpectedErrors(t *testing.T) {
+	ctx := context.Background()
+	storage := new(MockStorage)
+	storage.On("GetByID", mock.Anything, "notexist").Return("", ErrNotFound)
+	storage.On("GetByID", mock.Anything, "dberror1").Return("", errors.New("connection refused"))
+
+	appMetrics := NewMetrics(metrics.NewRegistry(), storage, logger.Discard())
+	wrapped := InstrumentStorage(storage, appMetrics)
+
+	_, err := wrapped.GetByID(ctx, "notexist")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = wrapped.GetByID(ctx, "dberror1")
+	assert.Error(t, err)
+
+	assert.Equal(t, float64(1), appMetrics.storageErrors.Value("unknown", "get_by_id"),
+		"ErrNotFound не должен считаться ошибкой хранилища")
+}
+
+// failingPinger - хранилище, проверка связи с которым всегда неудачна.
+type failingPinger struct {
+	*MockStorage
+}
+
+func (failingPinger) Ping(context.Context) error {
+	return errors.New("connection refused")
+}
+
+func TestInstrumentStorage_CountsCapabilityErrors(t *testing.T) {
+	storage := failingPinger{MockStorage: new(MockStorage)}
+	appMetrics := NewMetrics(metrics.NewRegistry(), storage, logger.Discard())
+	health := NewHealthHandler(InstrumentStorage(storage, appMetrics), logger.Discard())
+
+	rec := httptest.NewRecorder()
+	health.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, float64(1), appMetrics.storageErrors.Value("unknown", "ping"))
+}
+
+// countingStorage - хранилище в памяти, считающее вызовы CountLinks.
+type countingStorage struct {
+	*InMemoryStorage
+	counts atomic.Int32
+}
+
+func (s *countingStorage) CountLinks(ctx context.Context) (int64, error) {
+	s.counts.Add(1)
+	return s.InMemoryStorage.CountLinks(ctx)
+}
+
+func TestNewMetrics_CachesLinkCount(t *testing.T) {
+	storage := &countingStorage{InMemoryStorage: NewInMemoryStorage()}
+	require.NoError(t, storage.Save(context.Background(), URLRecord{ID: "abcdef12", OriginalURL: "https://yandex.ru"}))
+	reg := metrics.NewRegistry()
+	NewMetrics(reg, storage, logger.Discard())
+
+	for range 3 {
+		var body strings.Builder
+		_, err := reg.WriteTo(&body)
+		require.NoError(t, err)
+		assert.Contains(t, body.String(), "shortener_links 1\n")
+	}
+	assert.EqualValues(t, 1, storage.counts.Load(), "сбор метрик не пересчитывает ссылки каждый раз")
+}
+
+func TestMetricsMiddleware_UsesRoutePattern(t *testing.T) {
+	reg := metrics.NewRegistry()
+	storage := NewInMemoryStorage()
+	require.NoError(t, storage.Save(context.Background(), URLRecord{ID: "abcdef12", OriginalURL: "https://yandex.ru"}))
+	appMetrics := NewMetrics(reg, storage, logger.Discard())
+
+	r := chi.NewRouter()
+	r.Use(MetricsMiddleware(appMetrics))
+	r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTemporaryRedirect)
+	})
+	r.Get("/metrics", reg.Handler().ServeHTTP)
+
+	for _, path := range []string{"/abcdef12", "/ABCDEF34", "/a/b"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	assert.Equal(t, float64(2), appMetrics.httpRequests.Value(http.MethodGet, "/{id}", "307"))
+	assert.Equal(t, float64(1), appMetrics.httpRequests.Value(http.MethodGet, unmatchedRoute, "404"))
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rr.Body.String()
+	assert.Contains(t, body, `shortener_http_requests_total{method="GET",route="/{id}",status="307"} 2`)
+	assert.Contains(t, body, "shortener_links 1\n")
+	assert.True(t, strings.HasPrefix(rr.Header().Get("Content-Type"), "text/plain"))
+}
@@ -2,59 +2,64 @@ package app
 
 import (
 	"bytes"
+	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 
 	"github.com/cmpxNot29a/shurs/internal/helper"
 	"github.com/go-chi/chi/v5"
 )
 
-const defaultMiddlewareIDLengthFallback = 8
-
-// ValidateURLMiddleware проверяет URL в теле POST запроса.
-func ValidateURLMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		bodyBytes, err := io.ReadAll(r.Body)
-		if err != nil {
-			log.Printf("ERROR: Middleware (URL): Failed to read request body: %v", err)
-			http.Error(w, "Cannot read request body", http.StatusInternalServerError)
-			return
-		}
-		r.Body.Close()
-		originalURL := string(bodyBytes)
-
-		if !helper.IsValidURL(originalURL) {
-			log.Printf("WARN: Middleware (URL): Invalid URL received: %s", originalURL)
-			http.Error(w, "Invalid URL format", http.StatusBadRequest)
-			return
-		}
-		r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-		log.Println("INFO: Middleware (URL): Validation successful.")
-		next.ServeHTTP(w, r)
-	})
-}
+// ValidateURLMiddleware создает middleware, которое проверяет URL в теле POST запроса.
+func ValidateURLMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	log := logger.With("component", "middleware.url")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bodyBytes, err := io.ReadAll(r.Body)
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				log.Warn("Request body too large", "limit", tooLarge.Limit)
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				log.Error("Failed to read request body", "error", err)
+				http.Error(w, "Cannot read request body", http.StatusInternalServerError)
+				return
+			}
+			r.Body.Close()
+			originalURL := string(bodyBytes)
 
-// ValidateIDMiddleware создает middleware, которое проверяет ID указанной длины.
-func ValidateIDMiddleware(expectedIDLength int) func(http.Handler) http.Handler {
-	localExpectedLength := expectedIDLength
-	if localExpectedLength <= 0 {
-		log.Printf("WARN (Middleware Factory): Invalid expected ID length (%d) passed, using fallback %d for validation",
-			expectedIDLength, defaultMiddlewareIDLengthFallback)
-		localExpectedLength = defaultMiddlewareIDLengthFallback
+			if !helper.IsValidURL(originalURL) {
+				log.Warn("Invalid URL received", "url", originalURL)
+				http.Error(w, "Invalid URL format", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+			log.Debug("Validation successful")
+			next.ServeHTTP(w, r)
+		})
 	}
+}
+
+// ValidateIDMiddleware создает middleware, которое проверяет, что ID соответствует
+// формату генератора (см. IDGenerator.Format) или является допустимым алиасом (см. ValidateAlias).
+func ValidateIDMiddleware(format IDFormat, logger *slog.Logger) func(http.Handler) http.Handler {
+	log := logger.With("component", "middleware.id")
 
 	middlewareFunc := func(next http.Handler) http.Handler {
 		requestHandlerFunc := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			idFromURL := chi.URLParam(r, "id")
-			if !helper.IsValidBase62String(idFromURL, localExpectedLength) {
-				log.Printf("WARN: Middleware (ID): Invalid ID format received (len != %d or invalid chars): %s",
-					localExpectedLength, idFromURL)
+			if !format.Match(idFromURL) && ValidateAlias(idFromURL) != nil {
+				log.Warn("Invalid ID format received", "id", idFromURL,
+					"min_length", format.MinLength, "max_length", format.MaxLength)
 				http.Error(w, "Invalid ID format", http.StatusBadRequest)
 				return
 			}
 
-			log.Printf("INFO: Middleware (ID): Validation successful for ID: %s", idFromURL)
+			log.Debug("Validation successful", "id", idFromURL)
 			next.ServeHTTP(w, r)
 		})
 		return requestHandlerFunc
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/cmpxNot29a/shurs/internal/auth"
+)
+
+const (
+	// AuthCookieName - имя cookie с подписанным ID пользователя.
+	AuthCookieName = "auth"
+	// authCookieMaxAge - срок жизни cookie в секундах (один год).
+	authCookieMaxAge = 365 * 24 * 60 * 60
+)
+
+// AuthMiddleware создает middleware, которое проверяет подписанную cookie с ID пользователя
+// и кладет ID в контекст запроса. Если cookie нет или подпись неверна, выдается новый ID.
+// Cookie, подписанная предыдущим ключом, принимается и переподписывается текущим.
+func AuthMiddleware(signer *auth.Signer, logger *slog.Logger) func(http.Handler) http.Handler {
+	log := logger.With("component", "middleware.auth")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			userID, rotated, err := userFromCookie(r, signer)
+			if err != nil {
+				userID, err = auth.NewUserID()
+				if err != nil {
+					log.Error("Failed to issue user ID", "error", err)
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					return
+				}
+				log.Debug("Issued new user ID", "user_id", userID)
+				setAuthCookie(w, r, signer, userID)
+				ctx = auth.WithIssuedUserID(ctx, userID)
+			} else {
+				if rotated {
+					log.Debug("Re-signed cookie with current key", "user_id", userID)
+					setAuthCookie(w, r, signer, userID)
+				}
+				ctx = auth.WithUserID(ctx, userID)
+			}
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// userFromCookie достает и проверяет ID пользователя из cookie запроса.
+func userFromCookie(r *http.Request, signer *auth.Signer) (userID string, rotated bool, err error) {
+	cookie, err := r.Cookie(AuthCookieName)
+	if err != nil {
+		return "", false, err
+	}
+	return signer.Verify(cookie.Value)
+}
+
+func setAuthCookie(w http.ResponseWriter, r *http.Request, signer *auth.Signer, userID string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     AuthCookieName,
+		Value:    signer.Sign(userID),
+		Path:     "/",
+		MaxAge:   authCookieMaxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
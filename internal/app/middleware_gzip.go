@@ -0,0 +1,192 @@
+package app
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxDecompressedBodySize - наибольший размер распакованного тела запроса. Без предела
+// маленькое сжатое тело могло бы распаковаться в гигабайты.
+const maxDecompressedBodySize = 4 << 20
+
+// gzipReadCloser распаковывает тело запроса и закрывает и распаковщик, и исходное тело.
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (g *gzipReadCloser) Close() error {
+	g.Reader.Close()
+	return g.body.Close()
+}
+
+// gzipResponseWriter сжимает ответ, если он текстовый или JSON и не меньше minSize байт.
+// Пока решение не принято, ответ копится в буфере; статус и заголовки
+// отправляются клиенту только после решения.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	minSize  int
+	status   int
+	buf      []byte
+	gz       *gzip.Writer
+	decided  bool
+	compress bool
+}
+
+// WriteHeader запоминает статус; отправка откладывается до принятия решения о сжатии.
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	w.status = status
+	if !bodyAllowedForStatus(status) {
+		w.decide(false)
+	}
+}
+
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if w.decided {
+		if w.compress {
+			return w.gz.Write(p)
+		}
+		return w.ResponseWriter.Write(p)
+	}
+
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", http.DetectContentType(p))
+	}
+	if !isCompressibleType(w.Header().Get("Content-Type")) {
+		w.decide(false)
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) >= w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// decide отправляет заголовки и накопленный буфер, сжимая их при compress == true.
+func (w *gzipResponseWriter) decide(compress bool) error {
+	w.decided = true
+	w.compress = compress
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if compress {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.ResponseWriter.WriteHeader(w.status)
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+		_, err := w.gz.Write(w.buf)
+		w.buf = nil
+		return err
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) > 0 {
+		_, err := w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+		return err
+	}
+	return nil
+}
+
+// finish дописывает ответ: маленький ответ отправляется без сжатия, сжатый - закрывается.
+func (w *gzipResponseWriter) finish() error {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			return nil // обработчик ничего не записал, net/http сам ответит 200
+		}
+		return w.decide(false)
+	}
+	if w.compress {
+		return w.gz.Close()
+	}
+	return nil
+}
+
+// bodyAllowedForStatus сообщает, может ли ответ с таким статусом иметь тело.
+func bodyAllowedForStatus(status int) bool {
+	switch {
+	case status >= 100 && status <= 199:
+		return false
+	case status == http.StatusNoContent, status == http.StatusNotModified:
+		return false
+	}
+	return true
+}
+
+// isCompressibleType сообщает, стоит ли сжимать ответ с таким Content-Type.
+func isCompressibleType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasPrefix(mediaType, "text/")
+}
+
+// acceptsGzip сообщает, принимает ли клиент ответ в gzip ("gzip;q=0" означает отказ).
+func acceptsGzip(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		if name, value, ok := strings.Cut(params, "="); ok && strings.TrimSpace(name) == "q" {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			return err == nil && q > 0
+		}
+		return true
+	}
+	return false
+}
+
+// GzipMiddleware создает middleware, которое распаковывает тела запросов с
+// Content-Encoding: gzip и сжимает текстовые и JSON ответы не меньше minSize байт
+// для клиентов с Accept-Encoding: gzip. Чтение распакованного тела сверх
+// maxDecompressedBodySize завершается ошибкой *http.MaxBytesError.
+func GzipMiddleware(minSize int, logger *slog.Logger) func(http.Handler) http.Handler {
+	log := logger.With("component", "middleware.gzip")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.EqualFold(strings.TrimSpace(r.Header.Get("Content-Encoding")), "gzip") {
+				gz, err := gzip.NewReader(r.Body)
+				if err != nil {
+					log.Warn("Invalid gzip request body", "error", err)
+					http.Error(w, "Invalid gzip body", http.StatusBadRequest)
+					return
+				}
+				r.Body = http.MaxBytesReader(w, &gzipReadCloser{Reader: gz, body: r.Body}, maxDecompressedBodySize)
+				r.Header.Del("Content-Encoding")
+				r.Header.Del("Content-Length")
+				r.ContentLength = -1
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+			if !acceptsGzip(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			gw := &gzipResponseWriter{ResponseWriter: w, minSize: minSize}
+			next.ServeHTTP(gw, r)
+			if err := gw.finish(); err != nil {
+				log.Error("Failed to finish response", "error", err)
+			}
+		})
+	}
+}
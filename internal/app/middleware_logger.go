@@ -0,0 +1,46 @@
+package app
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// RequestLoggerMiddleware создает middleware, которое пишет одну структурированную
+// запись на каждый запрос: метод, путь, статус, размер ответа, длительность и ID запроса.
+// ID запроса берется из middleware.RequestID, поэтому оно должно стоять раньше.
+func RequestLoggerMiddleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	log := logger.With("component", "http")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			log.LogAttrs(r.Context(), levelForStatus(status), "Request handled",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int("size", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("request_id", middleware.GetReqID(r.Context())),
+			)
+		})
+	}
+}
+
+// levelForStatus выбирает уровень записи: 5xx - ошибка, остальное - информация.
+func levelForStatus(status int) slog.Level {
+	if status >= http.StatusInternalServerError {
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
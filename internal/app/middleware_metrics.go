@@ -0,0 +1,31 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// unmatchedRoute - метка route для запросов, не совпавших ни с одним маршрутом.
+const unmatchedRoute = "unmatched"
+
+// MetricsMiddleware создает middleware, которое считает запросы и их длительность
+// по методу, шаблону маршрута chi (а не пути, чтобы ID не раздували число серий) и статусу.
+func MetricsMiddleware(m *Metrics) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			next.ServeHTTP(ww, r)
+
+			route := unmatchedRoute
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				route = rctx.RoutePattern()
+			}
+			m.observeRequest(r.Method, route, ww.Status(), time.Since(start))
+		})
+	}
+}
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS urls (
+    id           BIGSERIAL PRIMARY KEY,
+    short_id     TEXT        NOT NULL,
+    original_url TEXT        NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS urls_short_id_uidx ON urls (short_id);
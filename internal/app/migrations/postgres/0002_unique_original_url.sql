@@ -0,0 +1,3 @@
+-- Обратный индекс для дедупликации: один исходный URL - одна короткая ссылка.
+-- Если в таблице уже есть дубликаты URL, миграция завершится ошибкой и их нужно удалить вручную.
+CREATE UNIQUE INDEX IF NOT EXISTS urls_original_url_uidx ON urls (original_url);
@@ -0,0 +1,5 @@
+-- Мягкое удаление: удаленные ссылки отвечают 410 Gone, а их URL можно сократить заново.
+ALTER TABLE urls ADD COLUMN is_deleted BOOLEAN NOT NULL DEFAULT FALSE;
+
+DROP INDEX IF EXISTS urls_original_url_uidx;
+CREATE UNIQUE INDEX urls_original_url_uidx ON urls (original_url) WHERE is_deleted = FALSE;
@@ -0,0 +1,5 @@
+-- Счетчики последовательных коротких ID; next_value - первое еще не выданное значение.
+CREATE TABLE IF NOT EXISTS id_sequences (
+    name       TEXT   PRIMARY KEY,
+    next_value BIGINT NOT NULL
+);
@@ -0,0 +1,4 @@
+-- Срок действия ссылки: NULL - бессрочная. Истекшие ссылки удаляются фоновой задачей.
+ALTER TABLE urls ADD COLUMN expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS urls_expires_at_idx ON urls (expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,3 @@
+-- Ограничение переходов: max_clicks - исходный лимит, clicks_left - остаток; NULL - без ограничения.
+ALTER TABLE urls ADD COLUMN max_clicks BIGINT;
+ALTER TABLE urls ADD COLUMN clicks_left BIGINT;
@@ -0,0 +1,2 @@
+-- Соленый хеш пароля ссылки; пустая строка - ссылка без пароля.
+ALTER TABLE urls ADD COLUMN password_hash TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,6 @@
+-- URL недействующей ссылки (истекшей или исчерпавшей переходы) освобождается для
+-- новой ссылки, хотя сама ссылка остается в таблице и отвечает 410 Gone.
+ALTER TABLE urls ADD COLUMN url_released BOOLEAN NOT NULL DEFAULT FALSE;
+
+DROP INDEX IF EXISTS urls_original_url_uidx;
+CREATE UNIQUE INDEX urls_original_url_uidx ON urls (original_url) WHERE is_deleted = FALSE AND url_released = FALSE;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS urls (
+    id           INTEGER PRIMARY KEY AUTOINCREMENT,
+    short_id     TEXT    NOT NULL,
+    original_url TEXT    NOT NULL,
+    created_at   TEXT    NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS urls_short_id_uidx ON urls (short_id);
@@ -0,0 +1,4 @@
+-- Создатель ссылки; пустая строка для ссылок, созданных до появления авторизации.
+ALTER TABLE urls ADD COLUMN user_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS urls_user_id_idx ON urls (user_id);
@@ -0,0 +1,4 @@
+-- Срок действия ссылки в Unix-миллисекундах: NULL - бессрочная. Истекшие ссылки удаляются фоновой задачей.
+ALTER TABLE urls ADD COLUMN expires_at INTEGER;
+
+CREATE INDEX IF NOT EXISTS urls_expires_at_idx ON urls (expires_at) WHERE expires_at IS NOT NULL;
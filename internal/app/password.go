@@ -0,0 +1,122 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// MaxPasswordLength - наибольшая длина пароля ссылки в байтах; длиннее bcrypt не различает.
+	MaxPasswordLength = 72
+	// passwordHashCost - стоимость bcrypt: подбор пароля по утекшему хешу должен быть дорогим.
+	passwordHashCost = bcrypt.DefaultCost
+	// unlockAttemptsLimit - сколько неудачных попыток ввести пароль ссылки дается одному
+	// клиенту за unlockAttemptsWindow.
+	unlockAttemptsLimit = 5
+	// unlockLinkAttemptsLimit - сколько неудачных попыток ввести пароль ссылки дается всем
+	// клиентам вместе за unlockAttemptsWindow: ограничивает подбор с многих адресов.
+	unlockLinkAttemptsLimit = 100
+	// unlockAttemptsWindow - окно, в котором считаются попытки ввести пароль.
+	unlockAttemptsWindow = time.Minute
+)
+
+var (
+	// ErrInvalidPassword - пароль для новой ссылки не прошел проверку HashPassword.
+	ErrInvalidPassword = errors.New("invalid password")
+	// ErrWrongPassword - введен неверный пароль защищенной ссылки.
+	ErrWrongPassword = errors.New("wrong password")
+	// ErrTooManyAttempts - исчерпаны попытки ввести пароль ссылки; нужно подождать.
+	ErrTooManyAttempts = errors.New("too many password attempts")
+)
+
+// HashPassword возвращает соленый хеш bcrypt пароля ссылки. Пароль длиннее
+// MaxPasswordLength байт отклоняется с ошибкой, оборачивающей ErrInvalidPassword.
+func HashPassword(password string) (string, error) {
+	if len(password) > MaxPasswordLength {
+		return "", fmt.Errorf("%w: must be at most %d bytes", ErrInvalidPassword, MaxPasswordLength)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordHashCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// checkPassword сообщает, соответствует ли пароль хешу из HashPassword.
+func checkPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// attemptLimiter ограничивает число попыток на ключ в фиксированном окне.
+// Счетчики живут в памяти процесса: у каждого экземпляра сервиса свой лимит.
+type attemptLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	window   time.Duration
+	attempts map[string]attemptWindow
+	swept    time.Time // время последней очистки устаревших окон
+}
+
+// attemptWindow - попытки по одному ключу, сделанные начиная со start.
+type attemptWindow struct {
+	start time.Time
+	count int
+}
+
+func newAttemptLimiter(limit int, window time.Duration) *attemptLimiter {
+	return &attemptLimiter{
+		limit:    limit,
+		window:   window,
+		attempts: make(map[string]attemptWindow),
+	}
+}
+
+// Allow учитывает попытку по ключу и сообщает, укладывается ли она в лимит.
+// Отклоненные попытки не продлевают окно.
+func (l *attemptLimiter) Allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.swept) >= l.window {
+		l.sweep(now)
+	}
+
+	w, exists := l.attempts[key]
+	if !exists || now.Sub(w.start) >= l.window {
+		w = attemptWindow{start: now}
+	}
+	if w.count >= l.limit {
+		return false
+	}
+	w.count++
+	l.attempts[key] = w
+	return true
+}
+
+// Refund отменяет попытку по ключу, учтенную Allow в тот же момент now: так в лимит
+// не идут попытки, которые оказались удачными.
+func (l *attemptLimiter) Refund(key string, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	w, exists := l.attempts[key]
+	if !exists || now.Sub(w.start) >= l.window || w.count == 0 {
+		return
+	}
+	w.count--
+	l.attempts[key] = w
+}
+
+// sweep удаляет окна, которые уже закончились, чтобы карта не росла без предела.
+func (l *attemptLimiter) sweep(now time.Time) {
+	for key, w := range l.attempts {
+		if now.Sub(w.start) >= l.window {
+			delete(l.attempts, key)
+		}
+	}
+	l.swept = now
+}
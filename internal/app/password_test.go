@@ -0,0 +1,46 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("s3cret")
+	require.NoError(t, err)
+	assert.NotContains(t, hash, "s3cret")
+	assert.True(t, checkPassword(hash, "s3cret"))
+	assert.False(t, checkPassword(hash, "S3cret"))
+
+	again, err := HashPassword("s3cret")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, again, "хеш должен быть соленым")
+
+	_, err = HashPassword(strings.Repeat("x", MaxPasswordLength+1))
+	assert.ErrorIs(t, err, ErrInvalidPassword)
+}
+
+func TestAttemptLimiter(t *testing.T) {
+	const window = time.Minute
+	limiter := newAttemptLimiter(2, window)
+	now := time.Now()
+
+	assert.True(t, limiter.Allow("abc", now))
+	assert.True(t, limiter.Allow("abc", now.Add(time.Second)))
+	assert.False(t, limiter.Allow("abc", now.Add(2*time.Second)), "третья попытка в окне отклоняется")
+	assert.True(t, limiter.Allow("xyz", now.Add(2*time.Second)), "лимит считается по ключу")
+
+	assert.True(t, limiter.Allow("abc", now.Add(window)), "с новым окном попытки снова доступны")
+	limiter.Refund("abc", now.Add(window))
+	assert.True(t, limiter.Allow("abc", now.Add(window)))
+	assert.True(t, limiter.Allow("abc", now.Add(window)), "отмененная попытка не входит в лимит")
+	assert.False(t, limiter.Allow("abc", now.Add(window)))
+
+	limiter.Allow("old", now)
+	limiter.Allow("new", now.Add(3*window))
+	assert.NotContains(t, limiter.attempts, "old", "закончившиеся окна удаляются")
+}
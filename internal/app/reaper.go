@@ -0,0 +1,78 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// ExpiryReaper периодически удаляет из хранилища ссылки с истекшим сроком действия.
+// До удаления такие ссылки отвечают 410, после - 404, а их ID и URL освобождаются.
+type ExpiryReaper struct {
+	purger   ExpiredPurger
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	logger   *slog.Logger
+}
+
+// NewExpiryReaper создает сборщик истекших ссылок и запускает его рабочую горутину.
+func NewExpiryReaper(purger ExpiredPurger, interval time.Duration, logger *slog.Logger) *ExpiryReaper {
+	r := &ExpiryReaper{
+		purger:   purger,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		logger:   logger.With("component", "reaper"),
+	}
+	go r.run()
+	return r
+}
+
+// Shutdown останавливает сборщик и ждет завершения текущего прохода.
+// Повторный вызов безопасен.
+func (r *ExpiryReaper) Shutdown(ctx context.Context) error {
+	select {
+	case <-r.stop:
+	default:
+		close(r.stop)
+	}
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *ExpiryReaper) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.reap()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// reap выполняет один проход; проход не может длиться дольше интервала между ними.
+func (r *ExpiryReaper) reap() {
+	ctx, cancel := context.WithTimeout(context.Background(), r.interval)
+	defer cancel()
+
+	purged, err := r.purger.PurgeExpired(ctx, time.Now())
+	if err != nil {
+		r.logger.Error("Failed to purge expired URLs", "error", err)
+		return
+	}
+	if purged > 0 {
+		r.logger.Info("Expired URLs purged", "count", purged)
+	}
+}
@@ -4,78 +4,583 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
-	"github.com/cmpxNot29a/shurs/internal/helper"
+	"github.com/cmpxNot29a/shurs/internal/auth"
 )
 
 type ShortenerUseCase interface {
 	CreateShortURL(ctx context.Context, originalURL string) (string, error)
+	CreateLink(ctx context.Context, originalURL string, opts LinkOptions) (string, error)
 	GetOriginalURL(ctx context.Context, id string) (string, error)
+	UnlockURL(ctx context.Context, id, password, client string) (string, error)
+	CreateShortURLBatch(ctx context.Context, originalURLs []string) ([]string, error)
+	GetUserURLs(ctx context.Context, cursor string, limit int) (URLPage, error)
+	GetLinkStats(ctx context.Context, id string) (LinkStats, error)
+	GetLinkReport(ctx context.Context, id string, query ReportQuery) (Breakdown, error)
+	GetUserReport(ctx context.Context, query ReportQuery) (Breakdown, error)
+	DeleteUserURLs(ctx context.Context, ids []string) error
+}
+
+// LinkOptions - необязательные параметры создаваемой ссылки.
+type LinkOptions struct {
+	Alias     string    // Пользовательский ID вместо сгенерированного; пустой - сгенерировать
+	ExpiresAt time.Time // Момент, с которого ссылка перестает работать; нулевой - бессрочная
+	MaxClicks int64     // Сколько раз можно перейти по ссылке; 0 - без ограничения
+	Password  string    // Пароль для перехода по ссылке; пустой - ссылка открыта всем
+}
+
+// ErrRestrictedURLExists - URL уже сокращен, и ограничения из LinkOptions к существующей
+// ссылке применить нельзя; выдать ее вместо запрошенной значило бы снять ограничения.
+var ErrRestrictedURLExists = errors.New("original URL already shortened, link restrictions cannot be applied")
+
+// restricted сообщает, ограничивают ли параметры доступ к ссылке.
+func (o LinkOptions) restricted() bool {
+	return !o.ExpiresAt.IsZero() || o.MaxClicks > 0 || o.Password != ""
+}
+
+// URLPage - страница ссылок пользователя; NextCursor пуст на последней странице.
+type URLPage struct {
+	Records    []URLRecord
+	NextCursor string
 }
 
 // ShortenerService инкапсулирует бизнес-логику сокращения URL.
 type ShortenerService struct {
-	storage  Storage
-	idLength int
-	attempts int
+	storage       Storage
+	idGenerator   IDGenerator
+	deterministic bool // ID зависит только от URL и попытки, см. DeterministicIDGenerator
+	attempts      int
+	deleter       *URLDeleter     // nil - удаление выполняется синхронно
+	analytics     AnalyticsStore  // nil - статистика переходов пустая
+	metrics       *Metrics        // nil - метрики не собираются
+	unlocks       *attemptLimiter // неудачные попытки ввести пароль по ссылке и клиенту
+	linkUnlocks   *attemptLimiter // неудачные попытки ввести пароль по ссылке
+	logger        *slog.Logger
 }
 
-func NewShortenerService(storage Storage, idLength int, attempts int) *ShortenerService {
+func NewShortenerService(storage Storage, idGenerator IDGenerator, attempts int, deleter *URLDeleter,
+	analytics AnalyticsStore, metrics *Metrics, logger *slog.Logger) *ShortenerService {
+	deterministic, ok := idGenerator.(DeterministicIDGenerator)
 
 	return &ShortenerService{
-		storage:  storage,
-		idLength: idLength,
-		attempts: attempts,
+		storage:       storage,
+		idGenerator:   idGenerator,
+		deterministic: ok && deterministic.Deterministic(),
+		attempts:      attempts,
+		deleter:       deleter,
+		analytics:     analytics,
+		metrics:       metrics,
+		unlocks:       newAttemptLimiter(unlockAttemptsLimit, unlockAttemptsWindow),
+		linkUnlocks:   newAttemptLimiter(unlockLinkAttemptsLimit, unlockAttemptsWindow),
+		logger:        logger.With("component", "service"),
 	}
 }
 
 // CreateShortURL генерирует уникальный ID, сохраняет URL и возвращает ID.
+// Владельцем ссылки записывается пользователь из контекста (см. auth.WithUserID).
+// Если URL уже сокращен ранее, возвращает существующий ID вместе с ErrURLExists.
 func (s *ShortenerService) CreateShortURL(ctx context.Context, originalURL string) (string, error) {
+	return s.CreateLink(ctx, originalURL, LinkOptions{})
+}
 
-	shortID, err := s.genUnicID(ctx)
-	if err != nil {
+// CreateLink сокращает URL с параметрами opts; в остальном работает как CreateShortURL.
+// Если URL уже сокращен, ограничения доступа (срок, лимит переходов, пароль) к
+// существующей ссылке не применить: возвращается ErrRestrictedURLExists без ID.
+// Пароль сохраняется только в виде хеша; недопустимый отклоняется с ErrInvalidPassword.
+func (s *ShortenerService) CreateLink(ctx context.Context, originalURL string, opts LinkOptions) (string, error) {
+	shortID, err := s.createLink(ctx, originalURL, opts)
+	if errors.Is(err, ErrURLExists) && opts.restricted() {
+		return "", ErrRestrictedURLExists
+	}
+	return shortID, err
+}
+
+func (s *ShortenerService) createLink(ctx context.Context, originalURL string, opts LinkOptions) (string, error) {
+	userID, _ := auth.UserIDFromContext(ctx)
+	rec := URLRecord{OriginalURL: originalURL, UserID: userID, CreatedAt: time.Now(), ExpiresAt: opts.ExpiresAt,
+		MaxClicks: opts.MaxClicks}
+	if opts.Password != "" {
+		hash, err := HashPassword(opts.Password)
+		if err != nil {
+			return "", err
+		}
+		rec.PasswordHash = hash
+	}
+	if opts.Alias != "" {
+		rec.ID = opts.Alias
+		return s.saveAlias(ctx, rec)
+	}
+
+	shortID, err := s.genUnicID(ctx, rec)
+	if errors.Is(err, ErrURLExists) && shortID == "" {
+		return s.existingID(ctx, originalURL)
+	}
+	return shortID, err
+}
+
+// saveAlias сохраняет запись под пользовательским алиасом rec.ID.
+// Недопустимый алиас отклоняется с ErrInvalidAlias, занятый (в том числе удаленной
+// ссылкой) - с ErrConflict. Если URL уже сокращен, возвращает существующий ID
+// вместе с ErrURLExists: у одного URL может быть только одна короткая ссылка.
+func (s *ShortenerService) saveAlias(ctx context.Context, rec URLRecord) (string, error) {
+	if err := ValidateAlias(rec.ID); err != nil {
 		return "", err
 	}
 
-	err = s.storage.Save(ctx, shortID, originalURL)
-	if err == nil {
-		return shortID, nil // Успешно сохранено
+	err := s.storage.Save(ctx, rec)
+	switch {
+	case errors.Is(err, ErrURLExists):
+		return s.existingID(ctx, rec.OriginalURL)
+	case errors.Is(err, ErrConflict):
+		return "", err
+	case err != nil:
+		s.logger.Error("Failed to save alias", "alias", rec.ID, "error", err)
+		return "", fmt.Errorf("storage error during save: %w", err)
 	}
+	return rec.ID, nil
+}
 
-	return "", fmt.Errorf("storage error during save: %w", err)
+// existingID возвращает ID ранее сокращенного URL вместе с ErrURLExists.
+func (s *ShortenerService) existingID(ctx context.Context, originalURL string) (string, error) {
+	shortID, err := s.storage.GetByOriginalURL(ctx, originalURL)
+	if err != nil {
+		s.logger.Error("Failed to get ID for existing URL", "url", originalURL, "error", err)
+		return "", fmt.Errorf("storage error during lookup by URL: %w", err)
+	}
+	return shortID, ErrURLExists
 }
 
-// GetOriginalURL получает оригинальный URL по ID.
+// CreateShortURLBatch сокращает пачку URL и возвращает ID в том же порядке.
+// Для уже сокращенных URL возвращаются существующие ID.
+// Если хранилище поддерживает BatchSaver, новые URL сохраняются одной операцией,
+// иначе каждый URL сохраняется отдельно. С детерминированным генератором URL тоже
+// сохраняются по одному: повтор всей пачки при коллизии дал бы URL другой ID,
+// чем при одиночном сокращении.
+func (s *ShortenerService) CreateShortURLBatch(ctx context.Context, originalURLs []string) ([]string, error) {
+	if saver, ok := storageCapability[BatchSaver](s.storage); ok && !s.deterministic {
+		return s.saveBatch(ctx, saver, originalURLs)
+	}
+
+	ids := make([]string, 0, len(originalURLs))
+	for _, originalURL := range originalURLs {
+		id, err := s.CreateShortURL(ctx, originalURL)
+		if err != nil && !errors.Is(err, ErrURLExists) {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// saveBatch находит ID уже сокращенных URL, генерирует ID для остальных и сохраняет
+// их одной операцией. При коллизии ID или если URL успели сократить параллельно,
+// пачка собирается заново.
+func (s *ShortenerService) saveBatch(ctx context.Context, saver BatchSaver, originalURLs []string) ([]string, error) {
+	userID, _ := auth.UserIDFromContext(ctx)
+	for attempt := range s.attempts {
+		if attempt > 0 {
+			s.metrics.idRetry()
+		}
+		idByURL, newURLs, err := s.resolveExisting(ctx, originalURLs)
+		if err != nil {
+			return nil, err
+		}
+
+		records, err := s.genBatchRecords(ctx, newURLs, userID, attempt)
+		if err != nil {
+			s.logger.Warn("Failed to generate batch IDs, retrying", "error", err)
+			continue
+		}
+
+		if len(records) > 0 {
+			err = saver.SaveBatch(ctx, records)
+			if errors.Is(err, ErrConflict) || errors.Is(err, ErrURLExists) {
+				if errors.Is(err, ErrConflict) {
+					s.observeCollision()
+				}
+				s.logger.Warn("Conflict detected in batch, retrying", "size", len(records), "error", err)
+				continue
+			}
+			if err != nil {
+				s.logger.Error("Failed to save batch", "size", len(records), "error", err)
+				return nil, fmt.Errorf("storage error during batch save: %w", err)
+			}
+			s.observeSuccess()
+		}
+
+		for _, rec := range records {
+			idByURL[rec.OriginalURL] = rec.ID
+		}
+		ids := make([]string, len(originalURLs))
+		for i, originalURL := range originalURLs {
+			ids[i] = idByURL[originalURL]
+		}
+		return ids, nil
+	}
+	return nil, fmt.Errorf("failed to save batch with unique IDs after %d attempts", s.attempts)
+}
+
+// resolveExisting делит URL пачки на уже сокращенные (с их ID) и новые.
+// Повторы внутри пачки попадают в новые только один раз.
+func (s *ShortenerService) resolveExisting(ctx context.Context, originalURLs []string) (map[string]string, []string, error) {
+	idByURL := make(map[string]string, len(originalURLs))
+	newURLs := make([]string, 0, len(originalURLs))
+	seen := make(map[string]struct{}, len(originalURLs))
+	for _, originalURL := range originalURLs {
+		if _, dup := seen[originalURL]; dup {
+			continue
+		}
+		seen[originalURL] = struct{}{}
+
+		id, err := s.storage.GetByOriginalURL(ctx, originalURL)
+		switch {
+		case err == nil:
+			idByURL[originalURL] = id
+		case errors.Is(err, ErrNotFound):
+			newURLs = append(newURLs, originalURL)
+		default:
+			s.logger.Error("Failed to look up URL", "url", originalURL, "error", err)
+			return nil, nil, fmt.Errorf("storage error during lookup by URL: %w", err)
+		}
+	}
+	return idByURL, newURLs, nil
+}
+
+// genBatchRecords генерирует по ID на каждый URL без повторов внутри пачки.
+// attempt - номер попытки сохранить пачку; при повторе ID внутри пачки номер
+// попытки для этого URL увеличивается.
+func (s *ShortenerService) genBatchRecords(ctx context.Context, originalURLs []string, userID string, attempt int) ([]URLRecord, error) {
+	records := make([]URLRecord, 0, len(originalURLs))
+	seen := make(map[string]struct{}, len(originalURLs))
+	for _, originalURL := range originalURLs {
+		id, err := s.genBatchID(ctx, originalURL, attempt, seen)
+		if err != nil {
+			return nil, err
+		}
+		seen[id] = struct{}{}
+		records = append(records, URLRecord{ID: id, OriginalURL: originalURL, UserID: userID, CreatedAt: time.Now()})
+	}
+	return records, nil
+}
+
+// genBatchID генерирует ID для URL пачки, не совпадающий с уже выданными в ней.
+func (s *ShortenerService) genBatchID(ctx context.Context, originalURL string, attempt int, seen map[string]struct{}) (string, error) {
+	for i := range s.attempts {
+		id, err := s.idGenerator.NewID(ctx, originalURL, attempt+i)
+		if err != nil {
+			return "", err
+		}
+		if _, dup := seen[id]; !dup {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("failed to generate ID unique within batch after %d attempts", s.attempts)
+}
+
+// GetUserURLs возвращает страницу ссылок, созданных пользователем из контекста.
+func (s *ShortenerService) GetUserURLs(ctx context.Context, cursor string, limit int) (URLPage, error) {
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return URLPage{}, nil
+	}
+
+	records, next, err := s.storage.GetByUser(ctx, userID, cursor, limit)
+	if errors.Is(err, ErrInvalidCursor) {
+		return URLPage{}, err
+	}
+	if err != nil {
+		s.logger.Error("Failed to get user URLs", "user_id", userID, "error", err)
+		return URLPage{}, fmt.Errorf("storage error during get by user: %w", err)
+	}
+	return URLPage{Records: records, NextCursor: next}, nil
+}
+
+// GetLinkStats возвращает статистику переходов по ссылке. Статистику видит только
+// создатель ссылки, в том числе после ее удаления или истечения; для остальных,
+// как и для несуществующего ID, возвращается ErrNotFound.
+func (s *ShortenerService) GetLinkStats(ctx context.Context, id string) (LinkStats, error) {
+	link, err := s.ownLink(ctx, id)
+	if err != nil {
+		return LinkStats{}, err
+	}
+
+	if s.analytics == nil {
+		return LinkStats{}, nil
+	}
+	stats, err := s.analytics.Stats(ctx, link)
+	if err != nil {
+		s.logger.Error("Failed to get link stats", "id", id, "error", err)
+		return LinkStats{}, fmt.Errorf("analytics error during stats: %w", err)
+	}
+	return stats, nil
+}
+
+// GetLinkReport возвращает разбивку переходов по ссылке за период query.
+// Доступ такой же, как у GetLinkStats.
+func (s *ShortenerService) GetLinkReport(ctx context.Context, id string, query ReportQuery) (Breakdown, error) {
+	link, err := s.ownLink(ctx, id)
+	if err != nil {
+		return Breakdown{}, err
+	}
+
+	if s.analytics == nil {
+		return Breakdown{}, nil
+	}
+	report, err := s.analytics.LinkReport(ctx, link, query)
+	if err != nil {
+		s.logger.Error("Failed to get link report", "id", id, "error", err)
+		return Breakdown{}, fmt.Errorf("analytics error during report: %w", err)
+	}
+	return report, nil
+}
+
+// GetUserReport возвращает разбивку переходов по всем ссылкам пользователя из
+// контекста за период query. Без пользователя возвращается ErrNotFound.
+func (s *ShortenerService) GetUserReport(ctx context.Context, query ReportQuery) (Breakdown, error) {
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return Breakdown{}, ErrNotFound
+	}
+
+	if s.analytics == nil {
+		return Breakdown{}, nil
+	}
+	report, err := s.analytics.OwnerReport(ctx, userID, query)
+	if err != nil {
+		s.logger.Error("Failed to get user report", "user_id", userID, "error", err)
+		return Breakdown{}, fmt.Errorf("analytics error during report: %w", err)
+	}
+	return report, nil
+}
+
+// ownLink возвращает ссылку id для запросов к аналитике или ErrNotFound, если ссылки
+// нет или ее создатель - не пользователь из контекста. Удаленные и истекшие ссылки
+// доступны создателю.
+func (s *ShortenerService) ownLink(ctx context.Context, id string) (LinkRef, error) {
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok {
+		return LinkRef{}, ErrNotFound
+	}
+
+	rec, err := s.storage.GetRecord(ctx, id)
+	if errors.Is(err, ErrNotFound) {
+		return LinkRef{}, err
+	}
+	if err != nil && !isUnavailableLink(err) {
+		s.logger.Error("Failed to get URL by ID", "id", id, "error", err)
+		return LinkRef{}, fmt.Errorf("storage error during get: %w", err)
+	}
+	if rec.UserID != userID {
+		return LinkRef{}, ErrNotFound
+	}
+	return LinkRef{ID: id, CreatedAt: rec.CreatedAt}, nil
+}
+
+// DeleteUserURLs удаляет ссылки пользователя из контекста. Чужие ID молча пропускаются.
+// При наличии URLDeleter ID только ставятся в очередь, и удаление завершается позже.
+func (s *ShortenerService) DeleteUserURLs(ctx context.Context, ids []string) error {
+	userID, ok := auth.UserIDFromContext(ctx)
+	if !ok || len(ids) == 0 {
+		return nil
+	}
+
+	if s.deleter != nil {
+		return s.deleter.Enqueue(ctx, userID, ids)
+	}
+	if err := s.storage.DeleteByUser(ctx, userID, ids); err != nil {
+		s.logger.Error("Failed to delete user URLs", "user_id", userID, "error", err)
+		return fmt.Errorf("storage error during delete: %w", err)
+	}
+	return nil
+}
+
+// GetOriginalURL получает оригинальный URL по ID для перехода и засчитывает переход.
+// Для удаленной ссылки возвращает ErrDeleted, для истекшей - ErrExpired,
+// для ссылки с исчерпанными переходами - ErrClicksExhausted, для защищенной
+// паролем - ErrPasswordRequired (см. UnlockURL).
 func (s *ShortenerService) GetOriginalURL(ctx context.Context, id string) (string, error) {
-	originalURL, err := s.storage.GetByID(ctx, id)
-	if err != nil && !errors.Is(err, ErrNotFound) {
-		log.Printf("ERROR (Service): Failed to get URL by ID %s: %v", id, err)
+	originalURL, err := s.storage.UseLink(ctx, id, false)
+	if err != nil && !isUnavailableLink(err) && !errors.Is(err, ErrPasswordRequired) {
+		s.logger.Error("Failed to get URL by ID", "id", id, "error", err)
+		return "", fmt.Errorf("storage error during get: %w", err)
+	}
+	return originalURL, err
+}
+
+// UnlockURL работает как GetOriginalURL, но переходит и по ссылке с паролем, если
+// password верен, иначе возвращает ErrWrongPassword. Неудачные попытки ввести пароль
+// к одной ссылке ограничены для клиента client (например, IP-адреса) и для всех
+// клиентов вместе: сверх лимита возвращается ErrTooManyAttempts без проверки.
+// Удачные попытки в лимит не входят. Для ссылки без пароля password не проверяется.
+func (s *ShortenerService) UnlockURL(ctx context.Context, id, password, client string) (string, error) {
+	rec, err := s.storage.GetRecord(ctx, id)
+	if err != nil {
+		if isUnavailableLink(err) {
+			return "", err
+		}
+		s.logger.Error("Failed to get URL by ID", "id", id, "error", err)
+		return "", fmt.Errorf("storage error during get: %w", err)
+	}
+
+	if rec.Protected() {
+		now := time.Now()
+		if !s.allowUnlock(id, client, now) {
+			s.logger.Warn("Too many password attempts", "id", id)
+			return "", ErrTooManyAttempts
+		}
+		if !checkPassword(rec.PasswordHash, password) {
+			s.logger.Info("Wrong password for protected URL", "id", id)
+			return "", ErrWrongPassword
+		}
+		s.refundUnlock(id, client, now)
+	}
+
+	originalURL, err := s.storage.UseLink(ctx, id, true)
+	if err != nil && !isUnavailableLink(err) {
+		s.logger.Error("Failed to get URL by ID", "id", id, "error", err)
 		return "", fmt.Errorf("storage error during get: %w", err)
 	}
 	return originalURL, err
 }
 
-func (s *ShortenerService) genUnicID(ctx context.Context) (string, error) {
-	for range s.attempts {
+// allowUnlock учитывает попытку клиента client ввести пароль ссылки id и сообщает,
+// укладывается ли она в лимиты. Попытка учитывается до проверки пароля, чтобы
+// одновременные запросы не обходили лимит; удачную попытку отменяет refundUnlock.
+func (s *ShortenerService) allowUnlock(id, client string, now time.Time) bool {
+	if !s.unlocks.Allow(unlockKey(id, client), now) {
+		return false
+	}
+	if !s.linkUnlocks.Allow(id, now) {
+		s.unlocks.Refund(unlockKey(id, client), now)
+		return false
+	}
+	return true
+}
 
-		randomIDBytes, err := helper.GenerateRandomBase62(s.idLength)
+// refundUnlock отменяет попытку, учтенную allowUnlock в момент now.
+func (s *ShortenerService) refundUnlock(id, client string, now time.Time) {
+	s.unlocks.Refund(unlockKey(id, client), now)
+	s.linkUnlocks.Refund(id, now)
+}
 
+// unlockKey - ключ попыток клиента client ввести пароль ссылки id; пробела в ID нет.
+func unlockKey(id, client string) string {
+	return id + " " + client
+}
+
+// isUnavailableLink сообщает, означает ли ошибка хранилища, что ссылки нет или
+// по ней нельзя перейти, а не сбой.
+func isUnavailableLink(err error) bool {
+	for _, target := range []error{ErrNotFound, ErrDeleted, ErrExpired, ErrClicksExhausted} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// genUnicID получает ID от генератора и сразу резервирует его в хранилище вместе
+// с остальными полями rec.
+// Коллизия ID (ErrConflict или отказ SaveIfAbsent) не считается ошибкой:
+// попытка повторяется с новым ID, пока не исчерпан лимит attempts.
+// Если URL уже сохранен, сразу возвращает ErrURLExists; ID при этом известен только
+// для детерминированного генератора, который находит URL среди своих кандидатов.
+// Коллизии и повторные попытки учитываются в метриках, а коллизии и успехи
+// передаются генератору, если он реализует CollisionObserver.
+func (s *ShortenerService) genUnicID(ctx context.Context, rec URLRecord) (string, error) {
+	for attempt := range s.attempts {
+		if attempt > 0 {
+			s.metrics.idRetry()
+		}
+
+		id, err := s.idGenerator.NewID(ctx, rec.OriginalURL, attempt)
 		if err != nil {
+			s.logger.Warn("Failed to generate ID, retrying", "error", err)
 			continue
 		}
 
-		randomID := string(randomIDBytes)
-		exists, err := s.storage.Exists(ctx, randomID)
+		if s.deterministic {
+			taken, err := s.probe(ctx, id, rec.OriginalURL)
+			if errors.Is(err, ErrURLExists) {
+				return id, err
+			}
+			if err != nil {
+				return "", err
+			}
+			if taken {
+				s.observeCollision()
+				s.logger.Warn("Collision detected for deterministic ID, retrying", "id", id, "attempt", attempt)
+				continue
+			}
+		}
+
+		rec.ID = id
+		saved, err := s.reserve(ctx, rec)
 
+		if errors.Is(err, ErrURLExists) {
+			return "", err
+		}
 		if err != nil {
-			log.Printf("ERROR (genUnicID): Failed to check existence for ID %s: %v", randomID, err)
-			return "", fmt.Errorf("failed to check ID existence: %w", err)
+			s.logger.Error("Failed to save ID", "id", id, "error", err)
+			return "", fmt.Errorf("storage error during save: %w", err)
 		}
-		if !exists {
-			return randomID, nil
+		if saved {
+			s.observeSuccess()
+			return id, nil
 		}
-		log.Printf("WARN: Handler: Collision detected for ID: %s. Retrying...", randomID)
+		s.observeCollision()
+		s.logger.Warn("Collision detected for ID, retrying", "id", id)
 	}
 	return "", fmt.Errorf("failed to generate unique ID after %d attempts", s.attempts)
 }
+
+// probe проверяет кандидата детерминированного генератора до резервирования.
+// Если под id уже сохранен этот же URL (с точностью до normalizeURL, по которому
+// генератор и выбирает ID), возвращает ErrURLExists: повторное сокращение идемпотентно
+// и не требует поиска по оригинальному URL. Если id занят другим
+// или недействующей ссылкой, возвращает true, и сервис переходит к следующей попытке.
+func (s *ShortenerService) probe(ctx context.Context, id, originalURL string) (bool, error) {
+	existing, err := s.storage.GetByID(ctx, id)
+	switch {
+	case err == nil && normalizeURL(existing) == normalizeURL(originalURL):
+		return true, ErrURLExists
+	case errors.Is(err, ErrNotFound):
+		return false, nil
+	case err == nil, isUnavailableLink(err):
+		return true, nil
+	default:
+		s.logger.Error("Failed to check ID", "id", id, "error", err)
+		return false, fmt.Errorf("storage error during get: %w", err)
+	}
+}
+
+// observeCollision учитывает коллизию ID в метриках и сообщает о ней генератору.
+func (s *ShortenerService) observeCollision() {
+	s.metrics.idCollision()
+	if observer, ok := s.idGenerator.(CollisionObserver); ok {
+		observer.ObserveCollision()
+	}
+}
+
+// observeSuccess сообщает генератору об успешном резервировании ID.
+func (s *ShortenerService) observeSuccess() {
+	if observer, ok := s.idGenerator.(CollisionObserver); ok {
+		observer.ObserveSuccess()
+	}
+}
+
+// reserve атомарно сохраняет запись, если ID свободен.
+// Использует SaveIfAbsent, если хранилище его поддерживает, иначе Save с проверкой ErrConflict.
+func (s *ShortenerService) reserve(ctx context.Context, rec URLRecord) (bool, error) {
+	if saver, ok := s.storage.(AbsentSaver); ok {
+		return saver.SaveIfAbsent(ctx, rec)
+	}
+
+	err := s.storage.Save(ctx, rec)
+	if errors.Is(err, ErrConflict) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"context"
+	"time"
+)
+
+// instrumentedService - декоратор ShortenerUseCase, измеряющий длительность операций.
+type instrumentedService struct {
+	service ShortenerUseCase
+	metrics *Metrics
+}
+
+// InstrumentService оборачивает сервис декоратором метрик.
+func InstrumentService(service ShortenerUseCase, m *Metrics) ShortenerUseCase {
+	return &instrumentedService{service: service, metrics: m}
+}
+
+func (s *instrumentedService) CreateShortURL(ctx context.Context, originalURL string) (string, error) {
+	start := time.Now()
+	id, err := s.service.CreateShortURL(ctx, originalURL)
+	s.metrics.observeOperation("create_short_url", err, time.Since(start))
+	return id, err
+}
+
+func (s *instrumentedService) CreateLink(ctx context.Context, originalURL string, opts LinkOptions) (string, error) {
+	start := time.Now()
+	id, err := s.service.CreateLink(ctx, originalURL, opts)
+	s.metrics.observeOperation("create_link", err, time.Since(start))
+	return id, err
+}
+
+func (s *instrumentedService) GetOriginalURL(ctx context.Context, id string) (string, error) {
+	start := time.Now()
+	originalURL, err := s.service.GetOriginalURL(ctx, id)
+	s.metrics.observeOperation("get_original_url", err, time.Since(start))
+	return originalURL, err
+}
+
+func (s *instrumentedService) UnlockURL(ctx context.Context, id, password, client string) (string, error) {
+	start := time.Now()
+	originalURL, err := s.service.UnlockURL(ctx, id, password, client)
+	s.metrics.observeOperation("unlock_url", err, time.Since(start))
+	return originalURL, err
+}
+
+func (s *instrumentedService) CreateShortURLBatch(ctx context.Context, originalURLs []string) ([]string, error) {
+	start := time.Now()
+	ids, err := s.service.CreateShortURLBatch(ctx, originalURLs)
+	s.metrics.observeOperation("create_short_url_batch", err, time.Since(start))
+	return ids, err
+}
+
+func (s *instrumentedService) GetUserURLs(ctx context.Context, cursor string, limit int) (URLPage, error) {
+	start := time.Now()
+	page, err := s.service.GetUserURLs(ctx, cursor, limit)
+	s.metrics.observeOperation("get_user_urls", err, time.Since(start))
+	return page, err
+}
+
+func (s *instrumentedService) GetLinkStats(ctx context.Context, id string) (LinkStats, error) {
+	start := time.Now()
+	stats, err := s.service.GetLinkStats(ctx, id)
+	s.metrics.observeOperation("get_link_stats", err, time.Since(start))
+	return stats, err
+}
+
+func (s *instrumentedService) GetLinkReport(ctx context.Context, id string, query ReportQuery) (Breakdown, error) {
+	start := time.Now()
+	report, err := s.service.GetLinkReport(ctx, id, query)
+	s.metrics.observeOperation("get_link_report", err, time.Since(start))
+	return report, err
+}
+
+func (s *instrumentedService) GetUserReport(ctx context.Context, query ReportQuery) (Breakdown, error) {
+	start := time.Now()
+	report, err := s.service.GetUserReport(ctx, query)
+	s.metrics.observeOperation("get_user_report", err, time.Since(start))
+	return report, err
+}
+
+func (s *instrumentedService) DeleteUserURLs(ctx context.Context, ids []string) error {
+	start := time.Now()
+	err := s.service.DeleteUserURLs(ctx, ids)
+	s.metrics.observeOperation("delete_user_urls", err, time.Since(start))
+	return err
+}
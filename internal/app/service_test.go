@@ -0,0 +1,216 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cmpxNot29a/shurs/internal/auth"
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/cmpxNot29a/shurs/internal/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// MockStorage мок для интерфейса Storage без необязательных возможностей.
+type MockStorage struct {
+	mock.Mock
+}
+
+var _ Storage = (*MockStorage)(nil)
+
+func (m *MockStorage) Save(ctx context.Context, rec URLRecord) error {
+	args := m.Called(ctx, rec)
+	return args.Error(0)
+}
+
+func (m *MockStorage) GetByID(ctx context.Context, id string) (string, error) {
+	args := m.Called(ctx, id)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockStorage) GetByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+	args := m.Called(ctx, originalURL)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockStorage) GetByUser(ctx context.Context, userID, cursor string, limit int) ([]URLRecord, string, error) {
+	args := m.Called(ctx, userID, cursor, limit)
+	records, _ := args.Get(0).([]URLRecord)
+	return records, args.String(1), args.Error(2)
+}
+
+func (m *MockStorage) GetRecord(ctx context.Context, id string) (URLRecord, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(URLRecord), args.Error(1)
+}
+
+func (m *MockStorage) UseLink(ctx context.Context, id string, unlocked bool) (string, error) {
+	args := m.Called(ctx, id, unlocked)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockStorage) DeleteByUser(ctx context.Context, userID string, ids []string) error {
+	return m.Called(ctx, userID, ids).Error(0)
+}
+
+func (m *MockStorage) Exists(ctx context.Context, id string) (bool, error) {
+	args := m.Called(ctx, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStorage) Close() error {
+	return m.Called().Error(0)
+}
+
+func TestShortenerService_CreateShortURL(t *testing.T) {
+	const testURL = "https://yandex.ru"
+	const testUserID = "user-1"
+
+	testCases := []struct {
+		name          string
+		attempts      int
+		saveResults   []error
+		expectErr     bool
+		expectedSaves int
+		collisions    float64
+		retries       float64
+	}{
+		{
+			name:          "Saved on first attempt",
+			attempts:      3,
+			saveResults:   []error{nil},
+			expectedSaves: 1,
+		},
+		{
+			name:          "Conflict is retried",
+			attempts:      3,
+			saveResults:   []error{ErrConflict, ErrConflict, nil},
+			expectedSaves: 3,
+			collisions:    2,
+			retries:       2,
+		},
+		{
+			name:          "Attempts exhausted by conflicts",
+			attempts:      2,
+			saveResults:   []error{ErrConflict, ErrConflict},
+			expectErr:     true,
+			expectedSaves: 2,
+			collisions:    2,
+			retries:       1,
+		},
+		{
+			name:          "Storage error is not retried",
+			attempts:      3,
+			saveResults:   []error{errors.New("connection refused")},
+			expectErr:     true,
+			expectedSaves: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			storage := new(MockStorage)
+			for _, res := range tc.saveResults {
+				storage.On("Save", mock.Anything, mock.MatchedBy(func(rec URLRecord) bool {
+					return rec.OriginalURL == testURL && rec.UserID == testUserID
+				})).Return(res).Once()
+			}
+
+			appMetrics := NewMetrics(metrics.NewRegistry(), storage, logger.Discard())
+			service := NewShortenerService(storage, newTestIDGenerator(t), tc.attempts, nil, nil, appMetrics, logger.Discard())
+			ctx := auth.WithUserID(context.Background(), testUserID)
+			id, err := service.CreateShortURL(ctx, testURL)
+
+			if tc.expectErr {
+				require.Error(t, err)
+				assert.Empty(t, id)
+			} else {
+				require.NoError(t, err)
+				assert.Len(t, id, 8)
+			}
+			storage.AssertNumberOfCalls(t, "Save", tc.expectedSaves)
+			storage.AssertNotCalled(t, "Exists", mock.Anything, mock.Anything)
+			assert.Equal(t, tc.collisions, appMetrics.idCollisions.Value(), "Неверное число коллизий")
+			assert.Equal(t, tc.retries, appMetrics.idRetries.Value(), "Неверное число повторов")
+		})
+	}
+}
+
+// MockAbsentSaver - MockStorage с возможностью AbsentSaver.
+type MockAbsentSaver struct {
+	MockStorage
+}
+
+var _ AbsentSaver = (*MockAbsentSaver)(nil)
+
+func (m *MockAbsentSaver) SaveIfAbsent(ctx context.Context, rec URLRecord) (bool, error) {
+	args := m.Called(ctx, rec)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestShortenerService_CreateShortURL_UsesSaveIfAbsent(t *testing.T) {
+	storage := new(MockAbsentSaver)
+	storage.On("SaveIfAbsent", mock.Anything, mock.MatchedBy(func(rec URLRecord) bool {
+		return rec.OriginalURL == "https://yandex.ru"
+	})).Return(false, nil).Once()
+	storage.On("SaveIfAbsent", mock.Anything, mock.Anything).Return(true, nil).Once()
+	service := NewShortenerService(storage, newTestIDGenerator(t), 3, nil, nil, nil, logger.Discard())
+
+	id, err := service.CreateShortURL(context.Background(), "https://yandex.ru")
+	require.NoError(t, err)
+	assert.Len(t, id, 8)
+
+	storage.AssertNumberOfCalls(t, "SaveIfAbsent", 2)
+	storage.AssertNotCalled(t, "Save", mock.Anything, mock.Anything)
+	storage.AssertNotCalled(t, "Exists", mock.Anything, mock.Anything)
+}
+
+func TestShortenerService_CreateShortURLBatch_Fallback(t *testing.T) {
+	storage := NewInMemoryStorage()
+	service := NewShortenerService(storage, newTestIDGenerator(t), 3, nil, nil, nil, logger.Discard())
+	urls := []string{"https://yandex.ru", "https://google.com", "https://ya.ru"}
+
+	ids, err := service.CreateShortURLBatch(context.Background(), urls)
+	require.NoError(t, err)
+	require.Len(t, ids, len(urls))
+
+	for i, id := range ids {
+		url, err := storage.GetByID(context.Background(), id)
+		require.NoError(t, err)
+		assert.Equal(t, urls[i], url)
+	}
+}
+
+func TestShortenerService_CreateShortURL_Deduplicates(t *testing.T) {
+	storage := NewInMemoryStorage()
+	service := NewShortenerService(storage, newTestIDGenerator(t), 3, nil, nil, nil, logger.Discard())
+	ctx := context.Background()
+
+	first, err := service.CreateShortURL(ctx, "https://yandex.ru")
+	require.NoError(t, err)
+
+	second, err := service.CreateShortURL(ctx, "https://yandex.ru")
+	assert.ErrorIs(t, err, ErrURLExists)
+	assert.NotErrorIs(t, err, ErrConflict)
+	assert.Equal(t, first, second)
+
+	ids, err := service.CreateShortURLBatch(ctx, []string{"https://google.com", "https://yandex.ru", "https://google.com"})
+	require.NoError(t, err)
+	assert.Equal(t, first, ids[1])
+	assert.Equal(t, ids[0], ids[2])
+}
+
+func TestShortenerService_DeleteUserURLs(t *testing.T) {
+	storage := new(MockStorage)
+	storage.On("DeleteByUser", mock.Anything, "user-1", []string{"abcdef12"}).Return(nil).Once()
+	service := NewShortenerService(storage, newTestIDGenerator(t), 3, nil, nil, nil, logger.Discard())
+
+	ctx := auth.WithUserID(context.Background(), "user-1")
+	require.NoError(t, service.DeleteUserURLs(ctx, []string{"abcdef12"}))
+
+	// Без пользователя в контексте удалять нечего.
+	require.NoError(t, service.DeleteUserURLs(context.Background(), []string{"abcdef12"}))
+	storage.AssertExpectations(t)
+}
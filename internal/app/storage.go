@@ -3,16 +3,177 @@ package app
 import (
 	"context"
 	"errors"
+	"strconv"
+	"time"
 )
 
 var (
 	ErrNotFound = errors.New("short link not found")
+	// ErrConflict - коллизия короткого ID: такой ID уже занят другой записью.
 	ErrConflict = errors.New("short link ID conflict or already exists")
+	// ErrURLExists - исходный URL уже сокращен ранее; это не коллизия ID.
+	ErrURLExists = errors.New("original URL already shortened")
+	// ErrDeleted - ссылка удалена владельцем.
+	ErrDeleted = errors.New("short link deleted")
+	// ErrExpired - срок действия ссылки истек.
+	ErrExpired = errors.New("short link expired")
+	// ErrClicksExhausted - по ссылке с ограничением переходов их больше не осталось.
+	ErrClicksExhausted = errors.New("short link click limit reached")
+	// ErrPasswordRequired - ссылка защищена паролем, и переход без него невозможен.
+	ErrPasswordRequired = errors.New("short link is password protected")
+	// ErrInvalidCursor - курсор постраничной выборки поврежден или выдан другим хранилищем.
+	ErrInvalidCursor = errors.New("invalid page cursor")
 )
 
+// Storage хранит соответствие короткий ID <-> исходный URL.
+// Save возвращает ErrURLExists, если URL уже сохранен, и ErrConflict, если занят ID.
+// GetByUser возвращает ссылки пользователя в порядке создания, не больше limit за раз;
+// cursor - непрозрачная строка из предыдущей страницы (пустая для первой),
+// next - курсор следующей страницы или пустая строка, если страниц больше нет.
+// DeleteByUser мягко удаляет ссылки: помечает удаленными только ID, созданные userID,
+// после чего GetByID возвращает ErrDeleted, а URL можно сократить заново.
+// Для ссылки с истекшим сроком действия GetByID возвращает ErrExpired, пока ссылка не
+// удалена (см. ExpiredPurger). URL истекшей ссылки и ссылки с исчерпанными переходами
+// свободен: GetByOriginalURL ее не находит, а Save сохраняет этот URL под новым ID,
+// не трогая саму ссылку.
+// UseLink работает как GetByID, но засчитывает переход: у ссылки с MaxClicks атомарно
+// уменьшает остаток переходов, а когда он исчерпан, возвращает ErrClicksExhausted
+// (как и GetByID). Проверки без перехода должны использовать GetByID.
+// По ссылке с паролем UseLink без unlocked возвращает ErrPasswordRequired и переход
+// не засчитывает; пароль проверяет вызывающий по хешу из GetRecord.
+// GetRecord возвращает запись целиком с теми же ошибками, что и GetByID; вместе
+// с ErrDeleted, ErrExpired и ErrClicksExhausted запись тоже возвращается.
+// CreatedAt хранилища с базой данных ставят сами при вставке, остальные сохраняют
+// переданный в Save.
 type Storage interface {
-	Save(ctx context.Context, id, originalURL string) error
+	Save(ctx context.Context, rec URLRecord) error
 	GetByID(ctx context.Context, id string) (originalURL string, err error)
+	GetRecord(ctx context.Context, id string) (URLRecord, error)
+	UseLink(ctx context.Context, id string, unlocked bool) (originalURL string, err error)
+	GetByOriginalURL(ctx context.Context, originalURL string) (id string, err error)
+	GetByUser(ctx context.Context, userID, cursor string, limit int) (records []URLRecord, next string, err error)
+	DeleteByUser(ctx context.Context, userID string, ids []string) error
 	Exists(ctx context.Context, id string) (bool, error)
 	Close() error
 }
+
+// AbsentSaver - необязательная возможность хранилища: атомарно сохранить запись,
+// только если ID еще не занят. saved == false означает коллизию ID, а не ошибку.
+// Позволяет сервису резервировать ID за один запрос к хранилищу без предварительного Exists.
+type AbsentSaver interface {
+	SaveIfAbsent(ctx context.Context, rec URLRecord) (saved bool, err error)
+}
+
+// Pinger - необязательная возможность хранилища: проверить, что оно доступно
+// (связь с внешней базой, запись в файл). Хранилища без этой возможности считаются
+// доступными всегда.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// LinkCounter - необязательная возможность хранилища: посчитать неудаленные ссылки.
+type LinkCounter interface {
+	CountLinks(ctx context.Context) (int64, error)
+}
+
+// SequenceAllocator - необязательная возможность хранилища: выдавать блоки значений
+// монотонного счетчика коротких ID. AllocateIDBlock резервирует size значений подряд
+// и возвращает первое из них; выданные блоки не пересекаются, в том числе после
+// перезапуска для хранилищ, переживающих его.
+type SequenceAllocator interface {
+	AllocateIDBlock(ctx context.Context, size int64) (start int64, err error)
+}
+
+// ExpiredPurger - необязательная возможность хранилища: окончательно удалить ссылки,
+// срок действия которых истек к моменту now. Возвращает число удаленных ссылок;
+// их ID и URL снова становятся свободными.
+type ExpiredPurger interface {
+	PurgeExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+// URLRecord - сохраняемая короткая ссылка.
+type URLRecord struct {
+	ID           string
+	OriginalURL  string
+	UserID       string    // Создатель ссылки; пустой, если пользователь неизвестен
+	CreatedAt    time.Time // Момент создания; вместе с ID отличает ссылку от прежних ссылок с тем же ID
+	Deleted      bool
+	ExpiresAt    time.Time // Момент, с которого ссылка не действует; нулевой - бессрочная
+	MaxClicks    int64     // Наибольшее число переходов; 0 - без ограничения
+	ClicksLeft   int64     // Оставшиеся переходы для ссылки с MaxClicks; при сохранении равен MaxClicks
+	PasswordHash string    // Соленый хеш пароля (см. HashPassword); пустой - ссылка без пароля
+}
+
+// Expired сообщает, истек ли срок действия ссылки к моменту now.
+func (r URLRecord) Expired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && !now.Before(r.ExpiresAt)
+}
+
+// ClicksExhausted сообщает, исчерпаны ли переходы по ссылке с ограничением.
+func (r URLRecord) ClicksExhausted() bool {
+	return r.MaxClicks > 0 && r.ClicksLeft <= 0
+}
+
+// releasesURL сообщает, освобождает ли ссылка к моменту now свой URL для новой ссылки.
+func (r URLRecord) releasesURL(now time.Time) bool {
+	return r.Expired(now) || r.ClicksExhausted()
+}
+
+// Protected сообщает, защищена ли ссылка паролем.
+func (r URLRecord) Protected() bool {
+	return r.PasswordHash != ""
+}
+
+// availability возвращает ошибку, по которой переход по ссылке невозможен к моменту now,
+// или nil, если ссылка действует.
+func (r URLRecord) availability(now time.Time) error {
+	switch {
+	case r.Deleted:
+		return ErrDeleted
+	case r.Expired(now):
+		return ErrExpired
+	case r.ClicksExhausted():
+		return ErrClicksExhausted
+	}
+	return nil
+}
+
+// BatchSaver - необязательная возможность хранилища: сохранить пачку записей атомарно
+// (все или ничего). При занятом ID возвращает ErrConflict, при уже сохраненном URL -
+// ErrURLExists, в обоих случаях не сохранив ни одной записи.
+// Хранилища без этой возможности сервис заполняет поштучно.
+type BatchSaver interface {
+	SaveBatch(ctx context.Context, records []URLRecord) error
+}
+
+// storageCapability возвращает необязательную возможность T хранилища. Для декоратора
+// (хранилища с методом Unwrap) наличие возможности определяется обернутым хранилищем,
+// а вызовы идут через сам декоратор.
+func storageCapability[T any](storage Storage) (T, bool) {
+	if wrapper, ok := storage.(interface{ Unwrap() Storage }); ok {
+		if _, ok := storageCapability[T](wrapper.Unwrap()); !ok {
+			var zero T
+			return zero, false
+		}
+	}
+	capability, ok := storage.(T)
+	return capability, ok
+}
+
+// parseCursor разбирает курсор-смещение, которым пользуются встроенные хранилища.
+// Пустой курсор означает начало выборки.
+func parseCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	pos, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil || pos < 0 {
+		return 0, ErrInvalidCursor
+	}
+	return pos, nil
+}
+
+// formatCursor кодирует позицию для следующей страницы.
+func formatCursor(pos int64) string {
+	return strconv.FormatInt(pos, 10)
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testUseLink проверяет ограничение числа переходов для любой реализации Storage:
+// ссылка без ограничения работает всегда, ссылка с ограничением - ровно MaxClicks раз.
+func testUseLink(t *testing.T, s Storage) {
+	t.Helper()
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "limited1", OriginalURL: "https://yandex.ru", MaxClicks: 2}))
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "forever1", OriginalURL: "https://ya.ru"}))
+
+	for range 2 {
+		url, err := s.UseLink(ctx, "limited1", false)
+		require.NoError(t, err)
+		assert.Equal(t, "https://yandex.ru", url)
+	}
+	_, err := s.UseLink(ctx, "limited1", false)
+	assert.ErrorIs(t, err, ErrClicksExhausted)
+	_, err = s.GetByID(ctx, "limited1")
+	assert.ErrorIs(t, err, ErrClicksExhausted)
+
+	_, err = s.GetByOriginalURL(ctx, "https://yandex.ru")
+	assert.ErrorIs(t, err, ErrNotFound, "ссылка с исчерпанными переходами не занимает URL")
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "limited2", OriginalURL: "https://yandex.ru"}))
+	id, err := s.GetByOriginalURL(ctx, "https://yandex.ru")
+	require.NoError(t, err)
+	assert.Equal(t, "limited2", id)
+	_, err = s.GetByID(ctx, "limited1")
+	assert.ErrorIs(t, err, ErrClicksExhausted, "сама исчерпанная ссылка остается")
+
+	for range 3 {
+		url, err := s.UseLink(ctx, "forever1", false)
+		require.NoError(t, err)
+		assert.Equal(t, "https://ya.ru", url)
+	}
+
+	_, err = s.UseLink(ctx, "missing1", false)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestInMemoryStorage_UseLink(t *testing.T) {
+	testUseLink(t, NewInMemoryStorage())
+}
+
+func TestSQLiteStorage_UseLink(t *testing.T) {
+	testUseLink(t, newTestSQLiteStorage(t))
+}
+
+func TestPostgresStorage_UseLink(t *testing.T) {
+	testUseLink(t, newTestPostgresStorage(t))
+}
+
+func TestFileStorage_RestoresClicksLeft(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	s, err := NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "limited1", OriginalURL: "https://yandex.ru", MaxClicks: 2}))
+	_, err = s.UseLink(ctx, "limited1", false)
+	require.NoError(t, err)
+	require.NoError(t, s.Close())
+
+	s, err = NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+
+	_, err = s.UseLink(ctx, "limited1", false)
+	require.NoError(t, err, "после перезапуска остался один переход")
+	_, err = s.UseLink(ctx, "limited1", false)
+	assert.ErrorIs(t, err, ErrClicksExhausted)
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "limited2", OriginalURL: "https://yandex.ru"}))
+	require.NoError(t, s.Close())
+
+	s, err = NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+	defer s.Close()
+	id, err := s.GetByOriginalURL(ctx, "https://yandex.ru")
+	require.NoError(t, err, "новая ссылка на URL исчерпанной восстанавливается")
+	assert.Equal(t, "limited2", id)
+}
+
+func TestFileStorage_UseLinkWriteFailure(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewFileStorage(filepath.Join(t.TempDir(), "storage.json"), logger.Discard())
+	require.NoError(t, err)
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "limited1", OriginalURL: "https://yandex.ru", MaxClicks: 1}))
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "forever1", OriginalURL: "https://ya.ru"}))
+	require.NoError(t, s.file.Close())
+
+	_, err = s.UseLink(ctx, "limited1", false)
+	assert.Error(t, err)
+	rec, err := s.GetRecord(ctx, "limited1")
+	require.NoError(t, err, "несохраненный переход не засчитывается")
+	assert.EqualValues(t, 1, rec.ClicksLeft)
+
+	url, err := s.UseLink(ctx, "forever1", false)
+	require.NoError(t, err, "переход без ограничения не пишется в файл")
+	assert.Equal(t, "https://ya.ru", url)
+}
+
+func TestShortenerService_ReshortenExhaustedURL(t *testing.T) {
+	service := NewShortenerService(NewInMemoryStorage(), newTestIDGenerator(t), 3, nil, nil, nil, logger.Discard())
+	ctx := context.Background()
+
+	oldID, err := service.CreateLink(ctx, "https://yandex.ru", LinkOptions{MaxClicks: 1})
+	require.NoError(t, err)
+	_, err = service.GetOriginalURL(ctx, oldID)
+	require.NoError(t, err)
+
+	newID, err := service.CreateShortURL(ctx, "https://yandex.ru")
+	require.NoError(t, err, "исчерпанная ссылка не должна возвращаться как уже существующая")
+	assert.NotEqual(t, oldID, newID)
+	_, err = service.GetOriginalURL(ctx, newID)
+	require.NoError(t, err)
+	_, err = service.GetOriginalURL(ctx, oldID)
+	assert.ErrorIs(t, err, ErrClicksExhausted)
+}
+
+func TestShortenerService_ClickLimitUnderConcurrency(t *testing.T) {
+	const (
+		maxClicks = 5
+		workers   = 50
+	)
+	storage := NewInMemoryStorage()
+	service := NewShortenerService(storage, newTestIDGenerator(t), 3, nil, nil, nil, logger.Discard())
+	ctx := context.Background()
+
+	id, err := service.CreateLink(ctx, "https://yandex.ru", LinkOptions{MaxClicks: maxClicks})
+	require.NoError(t, err)
+
+	var (
+		wg        sync.WaitGroup
+		succeeded atomic.Int64
+		exhausted atomic.Int64
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := service.GetOriginalURL(ctx, id)
+			switch {
+			case err == nil:
+				succeeded.Add(1)
+			case assert.ErrorIs(t, err, ErrClicksExhausted):
+				exhausted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, maxClicks, succeeded.Load(), "переходов должно быть ровно MaxClicks")
+	assert.EqualValues(t, workers-maxClicks, exhausted.Load())
+}
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDeleteByUser проверяет мягкое удаление для любой реализации Storage:
+// удаляются только ссылки владельца, удаленные пропадают из выдачи, а их URL
+// можно сократить заново.
+func testDeleteByUser(t *testing.T, s Storage) {
+	t.Helper()
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "owned001", OriginalURL: "https://yandex.ru", UserID: "owner"}))
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "owned002", OriginalURL: "https://google.com", UserID: "owner"}))
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "foreign1", OriginalURL: "https://ya.ru", UserID: "other"}))
+
+	require.NoError(t, s.DeleteByUser(ctx, "owner", []string{"owned001", "foreign1", "notexist"}))
+
+	_, err := s.GetByID(ctx, "owned001")
+	assert.ErrorIs(t, err, ErrDeleted)
+	_, err = s.GetByID(ctx, "notexist")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	url, err := s.GetByID(ctx, "foreign1")
+	require.NoError(t, err, "чужая ссылка не должна удаляться")
+	assert.Equal(t, "https://ya.ru", url)
+
+	records, _, err := s.GetByUser(ctx, "owner", "", 10)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "owned002", records[0].ID)
+
+	_, err = s.GetByOriginalURL(ctx, "https://yandex.ru")
+	assert.ErrorIs(t, err, ErrNotFound)
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "owned003", OriginalURL: "https://yandex.ru", UserID: "owner"}))
+
+	// Повторное удаление не меняет состояние.
+	require.NoError(t, s.DeleteByUser(ctx, "owner", []string{"owned001"}))
+}
+
+func TestInMemoryStorage_DeleteByUser(t *testing.T) {
+	testDeleteByUser(t, NewInMemoryStorage())
+}
+
+func TestSQLiteStorage_DeleteByUser(t *testing.T) {
+	testDeleteByUser(t, newTestSQLiteStorage(t))
+}
+
+func TestPostgresStorage_DeleteByUser(t *testing.T) {
+	testDeleteByUser(t, newTestPostgresStorage(t))
+}
+
+func TestFileStorage_DeleteByUserSurvivesReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	s, err := NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+	testDeleteByUser(t, s)
+	require.NoError(t, s.Close())
+
+	s, err = NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+	defer s.Close()
+
+	_, err = s.GetByID(ctx, "owned001")
+	assert.ErrorIs(t, err, ErrDeleted)
+
+	id, err := s.GetByOriginalURL(ctx, "https://yandex.ru")
+	require.NoError(t, err)
+	assert.Equal(t, "owned003", id)
+}
+
+func TestFileStorage_DeleteByUserWriteFailure(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewFileStorage(filepath.Join(t.TempDir(), "storage.json"), logger.Discard())
+	require.NoError(t, err)
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "owned001", OriginalURL: "https://yandex.ru", UserID: "owner"}))
+	require.NoError(t, s.file.Close())
+
+	assert.Error(t, s.DeleteByUser(ctx, "owner", []string{"owned001"}))
+	url, err := s.GetByID(ctx, "owned001")
+	require.NoError(t, err, "несохраненное удаление не должно применяться в памяти")
+	assert.Equal(t, "https://yandex.ru", url)
+}
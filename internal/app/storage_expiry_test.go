@@ -0,0 +1,175 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// expiringStorage - хранилище, умеющее удалять истекшие ссылки.
+type expiringStorage interface {
+	Storage
+	ExpiredPurger
+}
+
+// testPurgeExpired проверяет срок действия ссылок для любой реализации Storage:
+// истекшая ссылка отвечает ErrExpired и сразу освобождает свой URL, а после
+// PurgeExpired свободен и ее ID.
+func testPurgeExpired(t *testing.T, s expiringStorage) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "expired1", OriginalURL: "https://yandex.ru", UserID: "owner",
+		ExpiresAt: now.Add(-time.Hour)}))
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "future01", OriginalURL: "https://google.com", UserID: "owner",
+		ExpiresAt: now.Add(time.Hour)}))
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "forever1", OriginalURL: "https://ya.ru", UserID: "owner"}))
+
+	_, err := s.GetByID(ctx, "expired1")
+	assert.ErrorIs(t, err, ErrExpired)
+	url, err := s.GetByID(ctx, "future01")
+	require.NoError(t, err)
+	assert.Equal(t, "https://google.com", url)
+
+	_, err = s.GetByOriginalURL(ctx, "https://yandex.ru")
+	assert.ErrorIs(t, err, ErrNotFound, "истекшая ссылка не занимает URL")
+	assert.ErrorIs(t, s.Save(ctx, URLRecord{ID: "other001", OriginalURL: "https://google.com"}), ErrURLExists)
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "other001", OriginalURL: "https://yandex.ru"}),
+		"URL истекшей ссылки свободен и до ее удаления")
+	_, err = s.GetByID(ctx, "expired1")
+	assert.ErrorIs(t, err, ErrExpired, "сама истекшая ссылка остается до удаления")
+	id, err := s.GetByOriginalURL(ctx, "https://yandex.ru")
+	require.NoError(t, err)
+	assert.Equal(t, "other001", id)
+
+	purged, err := s.PurgeExpired(ctx, now)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, purged)
+
+	_, err = s.GetByID(ctx, "expired1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	id, err = s.GetByOriginalURL(ctx, "https://yandex.ru")
+	require.NoError(t, err)
+	assert.Equal(t, "other001", id, "удаление истекшей ссылки не трогает новую с тем же URL")
+	records, _, err := s.GetByUser(ctx, "owner", "", 10)
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "expired1", OriginalURL: "https://yandex.ru/new"}),
+		"после удаления ID можно занять снова")
+
+	purged, err = s.PurgeExpired(ctx, now)
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+
+	if saver, ok := s.(BatchSaver); ok {
+		require.NoError(t, s.Save(ctx, URLRecord{ID: "expired2", OriginalURL: "https://ya.ru/batch",
+			ExpiresAt: now.Add(-time.Hour)}))
+		require.NoError(t, saver.SaveBatch(ctx, []URLRecord{{ID: "batch001", OriginalURL: "https://ya.ru/batch"}}),
+			"пачка тоже занимает URL истекшей ссылки")
+	}
+}
+
+func TestInMemoryStorage_PurgeExpired(t *testing.T) {
+	testPurgeExpired(t, NewInMemoryStorage())
+}
+
+func TestSQLiteStorage_PurgeExpired(t *testing.T) {
+	testPurgeExpired(t, newTestSQLiteStorage(t))
+}
+
+func TestPostgresStorage_PurgeExpired(t *testing.T) {
+	testPurgeExpired(t, newTestPostgresStorage(t))
+}
+
+func TestShortenerService_ReshortenExpiredURL(t *testing.T) {
+	storage := NewInMemoryStorage()
+	service := NewShortenerService(storage, newTestIDGenerator(t), 3, nil, nil, nil, logger.Discard())
+	ctx := context.Background()
+
+	// Сборщик истекших ссылок не запущен: URL должен освободиться и без него.
+	oldID, err := service.CreateLink(ctx, "https://yandex.ru", LinkOptions{ExpiresAt: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+
+	newID, err := service.CreateShortURL(ctx, "https://yandex.ru")
+	require.NoError(t, err, "истекшая ссылка не должна возвращаться как уже существующая")
+	assert.NotEqual(t, oldID, newID)
+	url, err := service.GetOriginalURL(ctx, newID)
+	require.NoError(t, err)
+	assert.Equal(t, "https://yandex.ru", url)
+	_, err = service.GetOriginalURL(ctx, oldID)
+	assert.ErrorIs(t, err, ErrExpired)
+
+	id, err := service.CreateShortURL(ctx, "https://yandex.ru")
+	assert.ErrorIs(t, err, ErrURLExists)
+	assert.Equal(t, newID, id)
+}
+
+func TestFileStorage_SkipsExpiredOnRestore(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	s, err := NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "expired1", OriginalURL: "https://yandex.ru",
+		ExpiresAt: time.Now().Add(-time.Hour)}))
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "future01", OriginalURL: "https://google.com",
+		ExpiresAt: time.Now().Add(time.Hour)}))
+	require.NoError(t, s.Close())
+
+	s, err = NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+	defer s.Close()
+
+	_, err = s.GetByID(ctx, "expired1")
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = s.GetByID(ctx, "future01")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "future02", OriginalURL: "https://yandex.ru"}),
+		"URL истекшей ссылки свободен после перезапуска")
+}
+
+func TestExpiryReaper(t *testing.T) {
+	ctx := context.Background()
+	storage := NewInMemoryStorage()
+	require.NoError(t, storage.Save(ctx, URLRecord{ID: "expired1", OriginalURL: "https://yandex.ru",
+		ExpiresAt: time.Now().Add(-time.Second)}))
+
+	reaper := NewExpiryReaper(storage, 10*time.Millisecond, logger.Discard())
+	assert.Eventually(t, func() bool {
+		_, err := storage.GetByID(ctx, "expired1")
+		return errors.Is(err, ErrNotFound)
+	}, time.Second, 10*time.Millisecond, "сборщик должен удалить истекшую ссылку")
+
+	require.NoError(t, reaper.Shutdown(ctx))
+	require.NoError(t, reaper.Shutdown(ctx), "повторная остановка безопасна")
+}
+
+func TestShortenerService_ExpiringLink(t *testing.T) {
+	storage := NewInMemoryStorage()
+	service := NewShortenerService(storage, newTestIDGenerator(t), 3, nil, nil, nil, logger.Discard())
+	ctx := context.Background()
+
+	id, err := service.CreateLink(ctx, "https://yandex.ru", LinkOptions{ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = service.GetOriginalURL(ctx, id)
+	require.NoError(t, err)
+
+	_, err = storage.PurgeExpired(ctx, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	_, err = service.GetOriginalURL(ctx, id)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	id, err = service.CreateLink(ctx, "https://ya.ru", LinkOptions{ExpiresAt: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+	_, err = service.GetOriginalURL(ctx, id)
+	assert.ErrorIs(t, err, ErrExpired)
+}
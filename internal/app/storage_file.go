@@ -0,0 +1,347 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// fileRecord - одна строка файла хранилища в формате JSON Lines.
+type fileRecord struct {
+	UUID         string     `json:"uuid"`
+	ShortURL     string     `json:"short_url"`
+	OriginalURL  string     `json:"original_url"`
+	UserID       string     `json:"user_id,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	IsDeleted    bool       `json:"is_deleted,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	MaxClicks    int64      `json:"max_clicks,omitempty"`
+	PasswordHash string     `json:"password_hash,omitempty"`
+	ClicksLeft   *int64     `json:"clicks_left,omitempty"` // Строка перехода по ссылке с ограничением, а не ссылка
+	NextSeq      int64      `json:"next_seq,omitempty"`    // Строка выделения блока счетчика ID, а не ссылка
+}
+
+// FileStorage хранит ссылки в памяти и дописывает каждую новую запись в файл.
+// Удаление дописывается отдельной строкой с is_deleted: true, выделение блока
+// счетчика ID - строкой с next_seq, переход по ссылке с ограничением - строкой
+// с clicks_left.
+// При создании содержимое файла восстанавливается в память. Истекшие ссылки
+// удаляются только из памяти: при восстановлении они и так пропускаются.
+// Ошибка записи в файл не мешает следующим записям; пока файл не удается дописать,
+// Ping возвращает ошибку.
+type FileStorage struct {
+	mu          sync.Mutex // сериализует запись в файл; чтение идет напрямую из памяти
+	memory      *InMemoryStorage
+	file        *os.File
+	partialLine bool  // файл заканчивается незавершенной строкой
+	writeErr    error // ошибка последней записи в файл; nil, если она удалась
+	lastID      int
+	logger      *slog.Logger
+}
+
+// NewFileStorage открывает (или создает) файл хранилища и восстанавливает из него данные.
+// Поврежденные строки пропускаются с предупреждением.
+func NewFileStorage(path string, logger *slog.Logger) (*FileStorage, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage file %s: %w", path, err)
+	}
+
+	s := &FileStorage{
+		memory: NewInMemoryStorage(),
+		file:   file,
+		logger: logger.With("component", "storage.file"),
+	}
+
+	needsNewline, err := s.restore()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to restore storage from %s: %w", path, err)
+	}
+
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek storage file %s: %w", path, err)
+	}
+	// Обрезанная последняя строка не должна склеиться со следующей записью.
+	s.partialLine = needsNewline
+
+	return s, nil
+}
+
+// restore читает файл построчно и загружает записи в память.
+// Возвращает true, если файл не заканчивается переводом строки.
+func (s *FileStorage) restore() (bool, error) {
+	reader := bufio.NewReader(s.file)
+	ctx := context.Background()
+	lineNum := 0
+	restored := 0
+
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			lineNum++
+			s.restoreLine(ctx, line, lineNum, &restored)
+		}
+		if errors.Is(err, io.EOF) {
+			s.logger.Info("Storage restored", "records", restored, "path", s.file.Name())
+			return len(line) > 0, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+func (s *FileStorage) restoreLine(ctx context.Context, line []byte, lineNum int, restored *int) {
+	// Пустые строки оставляют Ping и завершение обрезанной строки.
+	if len(bytes.TrimSpace(line)) == 0 {
+		return
+	}
+	var rec fileRecord
+	if err := json.Unmarshal(line, &rec); err != nil {
+		s.logger.Warn("Skipping corrupt line", "line", lineNum, "error", err)
+		return
+	}
+	if id, err := strconv.Atoi(rec.UUID); err == nil && id > s.lastID {
+		s.lastID = id
+	}
+	if rec.NextSeq > 0 {
+		s.memory.advanceSequence(rec.NextSeq)
+		return
+	}
+	if rec.ClicksLeft != nil && rec.ShortURL != "" {
+		s.memory.restoreClicksLeft(rec.ShortURL, *rec.ClicksLeft)
+		return
+	}
+	if rec.ShortURL == "" || rec.OriginalURL == "" {
+		s.logger.Warn("Skipping incomplete record", "line", lineNum)
+		return
+	}
+	if rec.IsDeleted {
+		s.memory.deleteByUser(rec.UserID, []string{rec.ShortURL})
+		return
+	}
+	urlRec := URLRecord{ID: rec.ShortURL, OriginalURL: rec.OriginalURL, UserID: rec.UserID,
+		MaxClicks: rec.MaxClicks, PasswordHash: rec.PasswordHash}
+	if rec.CreatedAt != nil {
+		urlRec.CreatedAt = *rec.CreatedAt
+	}
+	if rec.ExpiresAt != nil {
+		urlRec.ExpiresAt = *rec.ExpiresAt
+		if urlRec.Expired(time.Now()) {
+			return
+		}
+	}
+	if err := s.memory.Save(ctx, urlRec); err != nil {
+		s.logger.Warn("Skipping record", "line", lineNum, "error", err)
+		return
+	}
+	*restored++
+}
+
+// Save реализует метод интерфейса Storage.
+func (s *FileStorage) Save(ctx context.Context, urlRec URLRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.memory.Save(ctx, urlRec); err != nil {
+		return err
+	}
+
+	rec := fileRecord{
+		UUID:         strconv.Itoa(s.lastID + 1),
+		ShortURL:     urlRec.ID,
+		OriginalURL:  urlRec.OriginalURL,
+		UserID:       urlRec.UserID,
+		MaxClicks:    urlRec.MaxClicks,
+		PasswordHash: urlRec.PasswordHash,
+	}
+	if !urlRec.CreatedAt.IsZero() {
+		createdAt := urlRec.CreatedAt.UTC()
+		rec.CreatedAt = &createdAt
+	}
+	if !urlRec.ExpiresAt.IsZero() {
+		expiresAt := urlRec.ExpiresAt.UTC()
+		rec.ExpiresAt = &expiresAt
+	}
+	if err := s.appendRecord(rec); err != nil {
+		s.memory.delete(urlRec.ID, urlRec.OriginalURL)
+		return fmt.Errorf("failed to write record to storage file: %w", err)
+	}
+	s.lastID++
+	return nil
+}
+
+// SaveIfAbsent реализует интерфейс AbsentSaver.
+func (s *FileStorage) SaveIfAbsent(ctx context.Context, rec URLRecord) (bool, error) {
+	err := s.Save(ctx, rec)
+	if errors.Is(err, ErrConflict) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// appendRecord дописывает запись в файл одним вызовом Write, без буфера, поэтому
+// неудачная запись не мешает следующим. Вызывается под s.mu.
+func (s *FileStorage) appendRecord(rec fileRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return s.writeLine(append(data, '\n'))
+}
+
+// writeLine дописывает в файл строку line, сначала завершив строку, которую не удалось
+// дописать целиком: при восстановлении такая строка пропускается как поврежденная.
+// Вызывается под s.mu.
+func (s *FileStorage) writeLine(line []byte) error {
+	if s.partialLine {
+		line = append([]byte{'\n'}, line...)
+	}
+	n, err := s.file.Write(line)
+	if n > 0 {
+		s.partialLine = line[n-1] != '\n'
+	}
+	s.writeErr = err
+	return err
+}
+
+// GetByID реализует метод интерфейса Storage.
+func (s *FileStorage) GetByID(ctx context.Context, id string) (string, error) {
+	return s.memory.GetByID(ctx, id)
+}
+
+// GetRecord реализует метод интерфейса Storage.
+func (s *FileStorage) GetRecord(ctx context.Context, id string) (URLRecord, error) {
+	return s.memory.GetRecord(ctx, id)
+}
+
+// UseLink реализует метод интерфейса Storage. Переход по ссылке с ограничением
+// дописывается в файл с новым остатком, чтобы он не восстановился после перезапуска;
+// если записать его не удалось, остаток в памяти возвращается. Такие переходы
+// сериализуются s.mu, поэтому возврат не смешивается с чужими переходами.
+func (s *FileStorage) UseLink(ctx context.Context, id string, unlocked bool) (string, error) {
+	// MaxClicks не меняется, так что ссылки без ограничения обходятся без s.mu.
+	if rec, err := s.memory.GetRecord(ctx, id); err == nil && rec.MaxClicks > 0 {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	originalURL, left, limited, err := s.memory.useLink(id, unlocked)
+	if err != nil || !limited {
+		return originalURL, err
+	}
+
+	rec := fileRecord{UUID: strconv.Itoa(s.lastID + 1), ShortURL: id, ClicksLeft: &left}
+	if err := s.appendRecord(rec); err != nil {
+		s.memory.refundClick(id)
+		return "", fmt.Errorf("failed to write click to storage file: %w", err)
+	}
+	s.lastID++
+	return originalURL, nil
+}
+
+// GetByOriginalURL реализует метод интерфейса Storage.
+func (s *FileStorage) GetByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+	return s.memory.GetByOriginalURL(ctx, originalURL)
+}
+
+// GetByUser реализует метод интерфейса Storage.
+func (s *FileStorage) GetByUser(ctx context.Context, userID, cursor string, limit int) ([]URLRecord, string, error) {
+	return s.memory.GetByUser(ctx, userID, cursor, limit)
+}
+
+// DeleteByUser реализует метод интерфейса Storage.
+// В файл дописываются только действительно удаленные ссылки. Как и в Save, удаление
+// сначала дописывается в файл и только затем отмечается в памяти; удаления
+// сериализуются s.mu, поэтому проверка записи перед дописыванием не устаревает.
+func (s *FileStorage) DeleteByUser(ctx context.Context, userID string, ids []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range ids {
+		urlRec, err := s.memory.GetRecord(ctx, id)
+		if errors.Is(err, ErrNotFound) || urlRec.Deleted || urlRec.UserID != userID {
+			continue
+		}
+		rec := fileRecord{
+			UUID:        strconv.Itoa(s.lastID + 1),
+			ShortURL:    id,
+			OriginalURL: urlRec.OriginalURL,
+			UserID:      userID,
+			IsDeleted:   true,
+		}
+		if err := s.appendRecord(rec); err != nil {
+			return fmt.Errorf("failed to write deletion to storage file: %w", err)
+		}
+		s.lastID++
+		s.memory.deleteByUser(userID, []string{id})
+	}
+	return nil
+}
+
+// AllocateIDBlock реализует интерфейс SequenceAllocator: конец блока дописывается в файл,
+// чтобы после перезапуска счетчик продолжился с него.
+func (s *FileStorage) AllocateIDBlock(ctx context.Context, size int64) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	start, err := s.memory.AllocateIDBlock(ctx, size)
+	if err != nil {
+		return 0, err
+	}
+	rec := fileRecord{UUID: strconv.Itoa(s.lastID + 1), NextSeq: start + size}
+	if err := s.appendRecord(rec); err != nil {
+		return 0, fmt.Errorf("failed to write ID sequence to storage file: %w", err)
+	}
+	s.lastID++
+	return start, nil
+}
+
+// PurgeExpired реализует интерфейс ExpiredPurger.
+func (s *FileStorage) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	return s.memory.PurgeExpired(ctx, now)
+}
+
+// CountLinks реализует интерфейс LinkCounter.
+func (s *FileStorage) CountLinks(ctx context.Context) (int64, error) {
+	return s.memory.CountLinks(ctx)
+}
+
+// Ping реализует интерфейс Pinger. После неудачной записи Ping проверяет, дописывается
+// ли файл снова, дописав в него пустую строку.
+func (s *FileStorage) Ping(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.writeErr == nil {
+		return nil
+	}
+	if err := s.writeLine([]byte{'\n'}); err != nil {
+		return fmt.Errorf("storage file is not writable: %w", err)
+	}
+	return nil
+}
+
+// Exists реализует метод интерфейса Storage.
+func (s *FileStorage) Exists(ctx context.Context, id string) (bool, error) {
+	return s.memory.Exists(ctx, id)
+}
+
+// Close реализует метод интерфейса Storage.
+func (s *FileStorage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStorage_RestoreAfterReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	s, err := NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+	createdAt := time.Date(2025, 3, 1, 12, 0, 0, 123, time.UTC)
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "abcdef12", OriginalURL: "https://yandex.ru", CreatedAt: createdAt}))
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "ABCDEF34", OriginalURL: "https://google.com"}))
+	assert.ErrorIs(t, s.Save(ctx, URLRecord{ID: "abcdef12", OriginalURL: "https://ya.ru"}), ErrConflict)
+	require.NoError(t, s.Close())
+
+	s, err = NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+	defer s.Close()
+
+	url, err := s.GetByID(ctx, "abcdef12")
+	require.NoError(t, err)
+	assert.Equal(t, "https://yandex.ru", url)
+
+	url, err = s.GetByID(ctx, "ABCDEF34")
+	require.NoError(t, err)
+	assert.Equal(t, "https://google.com", url)
+
+	rec, err := s.GetRecord(ctx, "abcdef12")
+	require.NoError(t, err)
+	assert.True(t, createdAt.Equal(rec.CreatedAt), "момент создания переживает перезапуск")
+}
+
+func TestFileStorage_SkipsCorruptLines(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	content := `{"uuid":"1","short_url":"abcdef12","original_url":"https://yandex.ru"}
+not a json line
+{"uuid":"2","short_url":"ABCDEF34","original_url":"https://goo`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	s, err := NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+
+	url, err := s.GetByID(ctx, "abcdef12")
+	require.NoError(t, err)
+	assert.Equal(t, "https://yandex.ru", url)
+
+	_, err = s.GetByID(ctx, "ABCDEF34")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// Запись после обрезанной строки должна попасть на отдельную строку.
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "newid123", OriginalURL: "https://ya.ru"}))
+	require.NoError(t, s.Close())
+
+	s, err = NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+	defer s.Close()
+
+	url, err = s.GetByID(ctx, "newid123")
+	require.NoError(t, err)
+	assert.Equal(t, "https://ya.ru", url)
+}
+
+func TestFileStorage_RecoversAfterWriteFailure(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	s, err := NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+	require.NoError(t, s.Ping(ctx))
+
+	file := s.file
+	broken, err := os.Open(path)
+	require.NoError(t, err)
+	require.NoError(t, broken.Close())
+	s.file = broken
+	assert.Error(t, s.Save(ctx, URLRecord{ID: "failed12", OriginalURL: "https://google.com"}))
+	assert.Error(t, s.Ping(ctx), "хранилище недоступно, пока файл не дописывается")
+
+	s.file = file
+	require.NoError(t, s.Ping(ctx))
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "abcdef12", OriginalURL: "https://yandex.ru"}),
+		"после неудачной записи следующие записи снова проходят")
+
+	// Строка, записанная не целиком, не склеивается со следующей записью.
+	_, err = s.file.WriteString(`{"uuid":"9","short_url":"cut`)
+	require.NoError(t, err)
+	s.partialLine = true
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "ABCDEF34", OriginalURL: "https://ya.ru"}))
+	require.NoError(t, s.Close())
+
+	s, err = NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+	defer s.Close()
+
+	for id, want := range map[string]string{"abcdef12": "https://yandex.ru", "ABCDEF34": "https://ya.ru"} {
+		url, err := s.GetByID(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, want, url)
+	}
+	_, err = s.GetByID(ctx, "failed12")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
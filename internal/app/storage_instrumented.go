@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// instrumentedStorage - декоратор Storage, учитывающий ошибки операций в метриках.
+// Декоратор реализует все необязательные возможности хранилища и передает их
+// обернутому; поддерживает ли их само хранилище, сообщает storageCapability.
+// Возможность AbsentSaver предоставляется всегда: если обернутое хранилище ее не
+// поддерживает, она выражается через Save так же, как это делает сервис.
+type instrumentedStorage struct {
+	storage Storage
+	metrics *Metrics
+}
+
+// InstrumentStorage оборачивает хранилище декоратором метрик, сохраняя его
+// необязательные возможности.
+func InstrumentStorage(storage Storage, m *Metrics) Storage {
+	return &instrumentedStorage{storage: storage, metrics: m}
+}
+
+// Unwrap возвращает обернутое хранилище.
+func (s *instrumentedStorage) Unwrap() Storage {
+	return s.storage
+}
+
+// Save реализует метод интерфейса Storage.
+func (s *instrumentedStorage) Save(ctx context.Context, rec URLRecord) error {
+	err := s.storage.Save(ctx, rec)
+	s.metrics.storageError("save", err)
+	return err
+}
+
+// SaveIfAbsent реализует интерфейс AbsentSaver.
+func (s *instrumentedStorage) SaveIfAbsent(ctx context.Context, rec URLRecord) (bool, error) {
+	if saver, ok := s.storage.(AbsentSaver); ok {
+		saved, err := saver.SaveIfAbsent(ctx, rec)
+		s.metrics.storageError("save_if_absent", err)
+		return saved, err
+	}
+
+	err := s.Save(ctx, rec)
+	if errors.Is(err, ErrConflict) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetByID реализует метод интерфейса Storage.
+func (s *instrumentedStorage) GetByID(ctx context.Context, id string) (string, error) {
+	originalURL, err := s.storage.GetByID(ctx, id)
+	s.metrics.storageError("get_by_id", err)
+	return originalURL, err
+}
+
+// GetRecord реализует метод интерфейса Storage.
+func (s *instrumentedStorage) GetRecord(ctx context.Context, id string) (URLRecord, error) {
+	rec, err := s.storage.GetRecord(ctx, id)
+	s.metrics.storageError("get_record", err)
+	return rec, err
+}
+
+// UseLink реализует метод интерфейса Storage.
+func (s *instrumentedStorage) UseLink(ctx context.Context, id string, unlocked bool) (string, error) {
+	originalURL, err := s.storage.UseLink(ctx, id, unlocked)
+	s.metrics.storageError("use_link", err)
+	return originalURL, err
+}
+
+// GetByOriginalURL реализует метод интерфейса Storage.
+func (s *instrumentedStorage) GetByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+	id, err := s.storage.GetByOriginalURL(ctx, originalURL)
+	s.metrics.storageError("get_by_original_url", err)
+	return id, err
+}
+
+// GetByUser реализует метод интерфейса Storage.
+func (s *instrumentedStorage) GetByUser(ctx context.Context, userID, cursor string, limit int) ([]URLRecord, string, error) {
+	records, next, err := s.storage.GetByUser(ctx, userID, cursor, limit)
+	s.metrics.storageError("get_by_user", err)
+	return records, next, err
+}
+
+// DeleteByUser реализует метод интерфейса Storage.
+func (s *instrumentedStorage) DeleteByUser(ctx context.Context, userID string, ids []string) error {
+	err := s.storage.DeleteByUser(ctx, userID, ids)
+	s.metrics.storageError("delete_by_user", err)
+	return err
+}
+
+// Exists реализует метод интерфейса Storage.
+func (s *instrumentedStorage) Exists(ctx context.Context, id string) (bool, error) {
+	exists, err := s.storage.Exists(ctx, id)
+	s.metrics.storageError("exists", err)
+	return exists, err
+}
+
+// Close реализует метод интерфейса Storage.
+func (s *instrumentedStorage) Close() error {
+	err := s.storage.Close()
+	s.metrics.storageError("close", err)
+	return err
+}
+
+// SaveBatch реализует интерфейс BatchSaver.
+func (s *instrumentedStorage) SaveBatch(ctx context.Context, records []URLRecord) error {
+	saver, ok := s.storage.(BatchSaver)
+	if !ok {
+		return errUnsupported("BatchSaver")
+	}
+	err := saver.SaveBatch(ctx, records)
+	s.metrics.storageError("save_batch", err)
+	return err
+}
+
+// Ping реализует интерфейс Pinger. Хранилище без Pinger считается доступным.
+func (s *instrumentedStorage) Ping(ctx context.Context) error {
+	pinger, ok := s.storage.(Pinger)
+	if !ok {
+		return nil
+	}
+	err := pinger.Ping(ctx)
+	s.metrics.storageError("ping", err)
+	return err
+}
+
+// CountLinks реализует интерфейс LinkCounter.
+func (s *instrumentedStorage) CountLinks(ctx context.Context) (int64, error) {
+	counter, ok := s.storage.(LinkCounter)
+	if !ok {
+		return 0, errUnsupported("LinkCounter")
+	}
+	n, err := counter.CountLinks(ctx)
+	s.metrics.storageError("count_links", err)
+	return n, err
+}
+
+// AllocateIDBlock реализует интерфейс SequenceAllocator.
+func (s *instrumentedStorage) AllocateIDBlock(ctx context.Context, size int64) (int64, error) {
+	allocator, ok := s.storage.(SequenceAllocator)
+	if !ok {
+		return 0, errUnsupported("SequenceAllocator")
+	}
+	start, err := allocator.AllocateIDBlock(ctx, size)
+	s.metrics.storageError("allocate_id_block", err)
+	return start, err
+}
+
+// PurgeExpired реализует интерфейс ExpiredPurger.
+func (s *instrumentedStorage) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	purger, ok := s.storage.(ExpiredPurger)
+	if !ok {
+		return 0, errUnsupported("ExpiredPurger")
+	}
+	purged, err := purger.PurgeExpired(ctx, now)
+	s.metrics.storageError("purge_expired", err)
+	return purged, err
+}
+
+// errUnsupported - ошибка вызова возможности, которой нет у обернутого хранилища.
+// Вызывающий код проверяет возможности через storageCapability и до нее не доходит.
+func errUnsupported(capability string) error {
+	return fmt.Errorf("storage does not support %s: %w", capability, errors.ErrUnsupported)
+}
@@ -2,44 +2,428 @@ package app
 
 import (
 	"context"
+	"errors"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// inMemoryShardCount - число шардов; степень двойки, чтобы номер шарда брался маской.
+const inMemoryShardCount = 32
+
+// inMemoryShard - часть хранилища со своей блокировкой.
+// data хранит записи по ID шарда, byURL - обратный индекс для URL, попавших в этот шард.
+type inMemoryShard struct {
+	mu    sync.RWMutex
+	data  map[string]URLRecord
+	byURL map[string]string
+}
+
+// InMemoryStorage - потокобезопасное хранилище в памяти.
+// Ключи распределены по шардам, у каждого шарда свой RWMutex,
+// поэтому конкурентные запросы к разным ID не блокируют друг друга.
+// byUser - ссылки каждого пользователя в порядке создания; его блокировка
+// берется только после блокировок шардов.
 type InMemoryStorage struct {
-	data map[string]string
+	shards   [inMemoryShardCount]*inMemoryShard
+	usersMu  sync.RWMutex
+	byUser   map[string][]userLink
+	lastLink int64        // номер последней сохраненной ссылки пользователя; защищен usersMu
+	nextSeq  atomic.Int64 // первое невыданное значение счетчика ID
+}
+
+// userLink - ссылка в списке пользователя. num растет с каждой сохраненной ссылкой
+// и не переиспользуется, поэтому служит курсором, как urls.id в базах данных.
+type userLink struct {
+	num int64
+	id  string
 }
 
 func NewInMemoryStorage() *InMemoryStorage {
-	return &InMemoryStorage{
-		data: make(map[string]string),
+	s := &InMemoryStorage{byUser: make(map[string][]userLink)}
+	for i := range s.shards {
+		s.shards[i] = &inMemoryShard{
+			data:  make(map[string]URLRecord),
+			byURL: make(map[string]string),
+		}
+	}
+	return s
+}
+
+// shardIndex возвращает номер шарда для ключа (ID или URL).
+func shardIndex(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32() & (inMemoryShardCount - 1)
+}
+
+// shard возвращает шард, отвечающий за указанный ключ.
+func (s *InMemoryStorage) shard(key string) *inMemoryShard {
+	return s.shards[shardIndex(key)]
+}
+
+// lockPair блокирует шарды ID и URL в порядке номеров, чтобы исключить взаимоблокировку,
+// и возвращает функцию разблокировки.
+func (s *InMemoryStorage) lockPair(id, originalURL string) (idShard, urlShard *inMemoryShard, unlock func()) {
+	i, j := shardIndex(id), shardIndex(originalURL)
+	idShard, urlShard = s.shards[i], s.shards[j]
+	if i == j {
+		idShard.mu.Lock()
+		return idShard, urlShard, idShard.mu.Unlock
+	}
+	first, second := idShard, urlShard
+	if j < i {
+		first, second = urlShard, idShard
+	}
+	first.mu.Lock()
+	second.mu.Lock()
+	return idShard, urlShard, func() {
+		second.mu.Unlock()
+		first.mu.Unlock()
 	}
 }
 
 // Save реализует метод интерфейса Storage.
-func (s *InMemoryStorage) Save(ctx context.Context, id, originalURL string) error {
+func (s *InMemoryStorage) Save(ctx context.Context, rec URLRecord) error {
+	s.releaseURL(rec.OriginalURL, time.Now())
+
+	idShard, urlShard, unlock := s.lockPair(rec.ID, rec.OriginalURL)
+	defer unlock()
 
-	if _, exists := s.data[id]; exists {
+	// Удаленные и недействующие ссылки убираются из обратного индекса, поэтому их URL
+	// можно сохранить снова.
+	if _, exists := urlShard.byURL[rec.OriginalURL]; exists {
+		return ErrURLExists
+	}
+	if _, exists := idShard.data[rec.ID]; exists {
 		return ErrConflict
 	}
-	s.data[id] = originalURL
+	rec.ClicksLeft = rec.MaxClicks
+	idShard.data[rec.ID] = rec
+	urlShard.byURL[rec.OriginalURL] = rec.ID
+
+	if rec.UserID != "" {
+		s.usersMu.Lock()
+		s.lastLink++
+		s.byUser[rec.UserID] = append(s.byUser[rec.UserID], userLink{num: s.lastLink, id: rec.ID})
+		s.usersMu.Unlock()
+	}
 	return nil
 }
 
+// releaseURL убирает URL из обратного индекса, если ссылка с ним освобождает его
+// (см. URLRecord.releasesURL). Сама ссылка остается под своим ID до удаления.
+func (s *InMemoryStorage) releaseURL(originalURL string, now time.Time) {
+	sh := s.shard(originalURL)
+	sh.mu.RLock()
+	id, exists := sh.byURL[originalURL]
+	sh.mu.RUnlock()
+	if !exists {
+		return
+	}
+
+	idShard, urlShard, unlock := s.lockPair(id, originalURL)
+	defer unlock()
+	if rec, exists := idShard.data[id]; exists && urlShard.byURL[originalURL] == id && rec.releasesURL(now) {
+		delete(urlShard.byURL, originalURL)
+	}
+}
+
+// SaveIfAbsent реализует интерфейс AbsentSaver.
+func (s *InMemoryStorage) SaveIfAbsent(ctx context.Context, rec URLRecord) (bool, error) {
+	err := s.Save(ctx, rec)
+	if errors.Is(err, ErrConflict) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 // GetByID реализует метод интерфейса Storage.
 func (s *InMemoryStorage) GetByID(ctx context.Context, id string) (string, error) {
+	sh := s.shard(id)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	rec, exists := sh.data[id]
+	if !exists {
+		return "", ErrNotFound
+	}
+	if err := rec.availability(time.Now()); err != nil {
+		return "", err
+	}
+	return rec.OriginalURL, nil
+}
+
+// GetRecord реализует метод интерфейса Storage.
+func (s *InMemoryStorage) GetRecord(ctx context.Context, id string) (URLRecord, error) {
+	sh := s.shard(id)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
+
+	rec, exists := sh.data[id]
+	if !exists {
+		return URLRecord{}, ErrNotFound
+	}
+	return rec, rec.availability(time.Now())
+}
+
+// UseLink реализует метод интерфейса Storage.
+func (s *InMemoryStorage) UseLink(ctx context.Context, id string, unlocked bool) (string, error) {
+	originalURL, _, _, err := s.useLink(id, unlocked)
+	return originalURL, err
+}
+
+// useLink засчитывает переход по ссылке. Для ссылки с ограничением возвращает
+// limited == true и остаток переходов после этого. Ссылки без ограничения
+// читаются под разделяемой блокировкой и не мешают друг другу.
+func (s *InMemoryStorage) useLink(id string, unlocked bool) (originalURL string, left int64, limited bool, err error) {
+	sh := s.shard(id)
+	sh.mu.RLock()
+	rec, exists := sh.data[id]
+	sh.mu.RUnlock()
+	if !exists {
+		return "", 0, false, ErrNotFound
+	}
+	if err := rec.availability(time.Now()); err != nil {
+		return "", 0, false, err
+	}
+	if rec.Protected() && !unlocked {
+		return "", 0, false, ErrPasswordRequired
+	}
+	if rec.MaxClicks == 0 {
+		return rec.OriginalURL, 0, false, nil
+	}
+
+	// Остаток проверяется и уменьшается под одной исключительной блокировкой.
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	rec, exists = sh.data[id]
+	if !exists {
+		return "", 0, false, ErrNotFound
+	}
+	if err := rec.availability(time.Now()); err != nil {
+		return "", 0, false, err
+	}
+	rec.ClicksLeft--
+	sh.data[id] = rec
+	return rec.OriginalURL, rec.ClicksLeft, true, nil
+}
+
+// restoreClicksLeft устанавливает остаток переходов при восстановлении; остаток
+// только уменьшается, поэтому строки, записанные не по порядку, ничего не портят.
+func (s *InMemoryStorage) restoreClicksLeft(id string, left int64) {
+	sh := s.shard(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	rec, exists := sh.data[id]
+	if exists && rec.MaxClicks > 0 && left < rec.ClicksLeft {
+		rec.ClicksLeft = left
+		sh.data[id] = rec
+	}
+}
+
+// refundClick возвращает переход, засчитанный useLink, если его не удалось сохранить.
+func (s *InMemoryStorage) refundClick(id string) {
+	sh := s.shard(id)
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	rec, exists := sh.data[id]
+	if exists && rec.MaxClicks > 0 && rec.ClicksLeft < rec.MaxClicks {
+		rec.ClicksLeft++
+		sh.data[id] = rec
+	}
+}
 
-	url, exists := s.data[id]
+// GetByOriginalURL реализует метод интерфейса Storage. Ссылка, освободившая URL,
+// не находится, даже если обратный индекс еще указывает на нее.
+func (s *InMemoryStorage) GetByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+	sh := s.shard(originalURL)
+	sh.mu.RLock()
+	id, exists := sh.byURL[originalURL]
+	sh.mu.RUnlock()
 	if !exists {
 		return "", ErrNotFound
 	}
-	return url, nil
+
+	idShard := s.shard(id)
+	idShard.mu.RLock()
+	defer idShard.mu.RUnlock()
+	rec, exists := idShard.data[id]
+	if !exists || rec.releasesURL(time.Now()) {
+		return "", ErrNotFound
+	}
+	return id, nil
+}
+
+// GetByUser реализует метод интерфейса Storage. Курсор - номер (userLink.num) последней
+// выданной ссылки, поэтому удаление ссылок из списка не сдвигает следующие страницы.
+// Удаленные ссылки пропускаются.
+func (s *InMemoryStorage) GetByUser(ctx context.Context, userID, cursor string, limit int) ([]URLRecord, string, error) {
+	after, err := parseCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	s.usersMu.RLock()
+	links := s.byUser[userID]
+	start := sort.Search(len(links), func(i int) bool { return links[i].num > after })
+	tail := append([]userLink(nil), links[start:]...)
+	s.usersMu.RUnlock()
+
+	records := make([]URLRecord, 0, min(limit, len(tail)))
+	next := ""
+	for _, link := range tail {
+		if len(records) == limit {
+			next = formatCursor(after)
+			break
+		}
+		sh := s.shard(link.id)
+		sh.mu.RLock()
+		rec, exists := sh.data[link.id]
+		sh.mu.RUnlock()
+		if exists && !rec.Deleted {
+			records = append(records, rec)
+			after = link.num
+		}
+	}
+	return records, next, nil
+}
+
+// DeleteByUser реализует метод интерфейса Storage.
+func (s *InMemoryStorage) DeleteByUser(ctx context.Context, userID string, ids []string) error {
+	s.deleteByUser(userID, ids)
+	return nil
+}
+
+// deleteByUser помечает удаленными ссылки пользователя и возвращает ID, которые
+// действительно были удалены (чужие, несуществующие и уже удаленные пропускаются).
+func (s *InMemoryStorage) deleteByUser(userID string, ids []string) []string {
+	deleted := make([]string, 0, len(ids))
+	for _, id := range ids {
+		sh := s.shard(id)
+		sh.mu.RLock()
+		rec, exists := sh.data[id]
+		sh.mu.RUnlock()
+		if !exists || rec.Deleted || rec.UserID != userID {
+			continue
+		}
+
+		idShard, urlShard, unlock := s.lockPair(id, rec.OriginalURL)
+		rec, exists = idShard.data[id]
+		if exists && !rec.Deleted && rec.UserID == userID {
+			rec.Deleted = true
+			idShard.data[id] = rec
+			if urlShard.byURL[rec.OriginalURL] == id {
+				delete(urlShard.byURL, rec.OriginalURL)
+			}
+			deleted = append(deleted, id)
+		}
+		unlock()
+	}
+	return deleted
+}
+
+// AllocateIDBlock реализует интерфейс SequenceAllocator. Счетчик живет только в памяти.
+func (s *InMemoryStorage) AllocateIDBlock(ctx context.Context, size int64) (int64, error) {
+	return s.nextSeq.Add(size) - size, nil
+}
+
+// advanceSequence поднимает счетчик ID до next, если он меньше; используется при восстановлении.
+func (s *InMemoryStorage) advanceSequence(next int64) {
+	for {
+		current := s.nextSeq.Load()
+		if current >= next || s.nextSeq.CompareAndSwap(current, next) {
+			return
+		}
+	}
+}
+
+// CountLinks реализует интерфейс LinkCounter.
+func (s *InMemoryStorage) CountLinks(ctx context.Context) (int64, error) {
+	var n int64
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for _, rec := range sh.data {
+			if !rec.Deleted {
+				n++
+			}
+		}
+		sh.mu.RUnlock()
+	}
+	return n, nil
+}
+
+// PurgeExpired реализует интерфейс ExpiredPurger.
+func (s *InMemoryStorage) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	var expired []URLRecord
+	for _, sh := range s.shards {
+		sh.mu.RLock()
+		for _, rec := range sh.data {
+			if rec.Expired(now) {
+				expired = append(expired, rec)
+			}
+		}
+		sh.mu.RUnlock()
+	}
+
+	// Между сбором и удалением ID мог освободиться и достаться новой записи,
+	// поэтому срок проверяется еще раз под блокировкой.
+	var purged int64
+	for _, rec := range expired {
+		if s.deleteIf(rec.ID, rec.OriginalURL, func(current URLRecord) bool {
+			return current.OriginalURL == rec.OriginalURL && current.Expired(now)
+		}) {
+			purged++
+		}
+	}
+	return purged, nil
 }
 
 // Exists реализует метод интерфейса Storage.
 func (s *InMemoryStorage) Exists(ctx context.Context, id string) (bool, error) {
+	sh := s.shard(id)
+	sh.mu.RLock()
+	defer sh.mu.RUnlock()
 
-	_, exists := s.data[id]
+	_, exists := sh.data[id]
 	return exists, nil
+}
+
+// delete удаляет запись; используется обертками для отката неудачного сохранения.
+func (s *InMemoryStorage) delete(id, originalURL string) {
+	s.deleteIf(id, originalURL, func(URLRecord) bool { return true })
+}
+
+// deleteIf удаляет запись, если она есть и удовлетворяет match, и сообщает, была ли она удалена.
+func (s *InMemoryStorage) deleteIf(id, originalURL string, match func(URLRecord) bool) bool {
+	idShard, urlShard, unlock := s.lockPair(id, originalURL)
+	defer unlock()
+
+	rec, exists := idShard.data[id]
+	if !exists || !match(rec) {
+		return false
+	}
+	delete(idShard.data, id)
+	if urlShard.byURL[originalURL] == id {
+		delete(urlShard.byURL, originalURL)
+	}
 
+	if rec.UserID != "" {
+		s.usersMu.Lock()
+		defer s.usersMu.Unlock()
+		links := s.byUser[rec.UserID]
+		for i := len(links) - 1; i >= 0; i-- {
+			if links[i].id == id {
+				s.byUser[rec.UserID] = append(links[:i], links[i+1:]...)
+				break
+			}
+		}
+	}
+	return true
 }
 
 // Close реализует метод интерфейса Storage.
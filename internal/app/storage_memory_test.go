@@ -0,0 +1,84 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryStorage(t *testing.T) {
+	s := NewInMemoryStorage()
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "abcdef12", OriginalURL: "https://yandex.ru"}))
+	assert.ErrorIs(t, s.Save(ctx, URLRecord{ID: "abcdef12", OriginalURL: "https://google.com"}), ErrConflict)
+
+	url, err := s.GetByID(ctx, "abcdef12")
+	require.NoError(t, err)
+	assert.Equal(t, "https://yandex.ru", url)
+
+	_, err = s.GetByID(ctx, "notexist")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	exists, err := s.Exists(ctx, "abcdef12")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	assert.ErrorIs(t, s.Save(ctx, URLRecord{ID: "other123", OriginalURL: "https://yandex.ru"}), ErrURLExists)
+	id, err := s.GetByOriginalURL(ctx, "https://yandex.ru")
+	require.NoError(t, err)
+	assert.Equal(t, "abcdef12", id)
+}
+
+// Стресс-тест для запуска с -race: много горутин одновременно пишут и читают
+// пересекающиеся наборы ID.
+func TestInMemoryStorage_ConcurrentAccess(t *testing.T) {
+	s := NewInMemoryStorage()
+	ctx := context.Background()
+
+	const (
+		workers = 32
+		ids     = 500
+	)
+	var (
+		wg    sync.WaitGroup
+		saved atomic.Int64
+	)
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range ids {
+				id := fmt.Sprintf("id%06d", i)
+				err := s.Save(ctx, URLRecord{ID: id, OriginalURL: "https://example.com/" + id})
+				switch {
+				case err == nil:
+					saved.Add(1)
+				case !errors.Is(err, ErrURLExists):
+					t.Errorf("worker %d: unexpected save error: %v", w, err)
+				}
+
+				if _, err := s.Exists(ctx, id); err != nil {
+					t.Errorf("worker %d: unexpected exists error: %v", w, err)
+				}
+				url, err := s.GetByID(ctx, id)
+				if err != nil {
+					t.Errorf("worker %d: id %s must be readable after save: %v", w, id, err)
+					continue
+				}
+				if url != "https://example.com/"+id {
+					t.Errorf("worker %d: id %s has wrong url %s", w, id, url)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.EqualValues(t, ids, saved.Load(), "каждый ID должен быть сохранен ровно один раз")
+}
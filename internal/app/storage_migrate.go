@@ -0,0 +1,139 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations
+var migrationsFS embed.FS
+
+// migration - одна версионированная миграция схемы.
+type migration struct {
+	version int
+	name    string
+	query   string
+}
+
+// loadMigrations читает миграции из каталога migrations/<dialect>.
+// Имя файла должно начинаться с номера версии: 0001_create_urls.sql.
+func loadMigrations(dialect string) ([]migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationsFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations dir %s: %w", dir, err)
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		prefix, _, found := strings.Cut(entry.Name(), "_")
+		if !found {
+			return nil, fmt.Errorf("migration %s has no version prefix", entry.Name())
+		}
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has invalid version: %w", entry.Name(), err)
+		}
+		query, err := fs.ReadFile(migrationsFS, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, migration{version: version, name: entry.Name(), query: string(query)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// migrationLockKey - ключ advisory-блокировки PostgreSQL, под которой применяются миграции.
+const migrationLockKey int64 = 0x73687572735f6d67
+
+// migrate применяет к базе все еще не примененные миграции указанного диалекта.
+// Чтение текущей версии и все новые миграции выполняются одной транзакцией под
+// блокировкой, поэтому экземпляры, запущенные одновременно, не применяют одну и ту же
+// версию дважды: в PostgreSQL это advisory-блокировка, в SQLite транзакция открывается
+// как BEGIN IMMEDIATE (_txlock=immediate в DSN) и сразу берет блокировку записи.
+func migrate(ctx context.Context, db *sql.DB, dialect string, logger *slog.Logger) error {
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get migration connection: %w", err)
+	}
+	defer conn.Close()
+
+	if dialect == "postgres" {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		// Блокировка принадлежит сессии: соединение вернется в пул, поэтому ее нужно
+		// снять явно, даже если ctx уже отменен.
+		defer conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin migrations: %w", err)
+	}
+	defer tx.Rollback()
+
+	applied, err := applyMigrations(ctx, tx, migrations)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migrations: %w", err)
+	}
+	for _, m := range applied {
+		logger.Info("Migration applied", "dialect", dialect, "migration", m.name)
+	}
+	return nil
+}
+
+// applyMigrations применяет в транзакции tx миграции новее текущей версии схемы
+// и возвращает примененные.
+func applyMigrations(ctx context.Context, tx *sql.Tx, migrations []migration) ([]migration, error) {
+	_, err := tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name    TEXT NOT NULL
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var current int
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read current schema version: %w", err)
+	}
+
+	var applied []migration
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, m.query); err != nil {
+			return nil, fmt.Errorf("failed to apply migration %s: %w", m.name, err)
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.version, m.name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record migration %s: %w", m.name, err)
+		}
+		applied = append(applied, m)
+	}
+	return applied, nil
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testGetByUserPagination проверяет постраничную выдачу ссылок пользователя
+// для любой реализации Storage.
+func testGetByUserPagination(t *testing.T, s Storage) {
+	t.Helper()
+	ctx := context.Background()
+
+	const total = 5
+	for i := range total {
+		require.NoError(t, s.Save(ctx, URLRecord{
+			ID:          fmt.Sprintf("user1id%d", i),
+			OriginalURL: fmt.Sprintf("https://example.com/%d", i),
+			UserID:      "user-1",
+		}))
+	}
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "user2id0", OriginalURL: "https://example.org", UserID: "user-2"}))
+
+	var (
+		got    []string
+		cursor string
+		pages  int
+	)
+	for {
+		records, next, err := s.GetByUser(ctx, "user-1", cursor, 2)
+		require.NoError(t, err)
+		pages++
+		for _, rec := range records {
+			assert.Equal(t, "user-1", rec.UserID)
+			got = append(got, rec.ID)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	assert.Equal(t, []string{"user1id0", "user1id1", "user1id2", "user1id3", "user1id4"}, got)
+	assert.Equal(t, 3, pages)
+
+	records, next, err := s.GetByUser(ctx, "nobody", "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+	assert.Empty(t, next)
+
+	_, _, err = s.GetByUser(ctx, "user-1", "not-a-cursor", 10)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+
+	// Удаление ссылок с прочитанных страниц не сдвигает следующие.
+	now := time.Now()
+	for i := range 4 {
+		rec := URLRecord{ID: fmt.Sprintf("user3id%d", i), OriginalURL: fmt.Sprintf("https://example.net/%d", i), UserID: "user-3"}
+		if i < 2 {
+			rec.ExpiresAt = now.Add(time.Hour)
+		}
+		require.NoError(t, s.Save(ctx, rec))
+	}
+	records, next, err = s.GetByUser(ctx, "user-3", "", 2)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	require.NotEmpty(t, next)
+	purged, err := s.(ExpiredPurger).PurgeExpired(ctx, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.EqualValues(t, 2, purged)
+	records, _, err = s.GetByUser(ctx, "user-3", next, 2)
+	require.NoError(t, err)
+	got = got[:0]
+	for _, rec := range records {
+		got = append(got, rec.ID)
+	}
+	assert.Equal(t, []string{"user3id2", "user3id3"}, got)
+}
+
+func TestInMemoryStorage_GetByUser(t *testing.T) {
+	testGetByUserPagination(t, NewInMemoryStorage())
+}
+
+func TestSQLiteStorage_GetByUser(t *testing.T) {
+	testGetByUserPagination(t, newTestSQLiteStorage(t))
+}
+
+func TestPostgresStorage_GetByUser(t *testing.T) {
+	testGetByUserPagination(t, newTestPostgresStorage(t))
+}
@@ -0,0 +1,120 @@
+package app
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/cmpxNot29a/shurs/internal/logger"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testProtectedLink проверяет ссылки с паролем для любой реализации Storage:
+// без unlocked переход не засчитывается, а хеш пароля доступен через GetRecord.
+func testProtectedLink(t *testing.T, s Storage) {
+	t.Helper()
+	ctx := context.Background()
+
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "secret01", OriginalURL: "https://yandex.ru",
+		MaxClicks: 1, PasswordHash: "hash"}))
+
+	rec, err := s.GetRecord(ctx, "secret01")
+	require.NoError(t, err)
+	assert.Equal(t, "https://yandex.ru", rec.OriginalURL)
+	assert.Equal(t, "hash", rec.PasswordHash)
+
+	_, err = s.UseLink(ctx, "secret01", false)
+	assert.ErrorIs(t, err, ErrPasswordRequired)
+	_, err = s.UseLink(ctx, "secret01", false)
+	assert.ErrorIs(t, err, ErrPasswordRequired, "переход без пароля не тратит лимит")
+
+	url, err := s.UseLink(ctx, "secret01", true)
+	require.NoError(t, err)
+	assert.Equal(t, "https://yandex.ru", url)
+	_, err = s.UseLink(ctx, "secret01", true)
+	assert.ErrorIs(t, err, ErrClicksExhausted)
+
+	_, err = s.GetRecord(ctx, "missing1")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestInMemoryStorage_ProtectedLink(t *testing.T) {
+	testProtectedLink(t, NewInMemoryStorage())
+}
+
+func TestSQLiteStorage_ProtectedLink(t *testing.T) {
+	testProtectedLink(t, newTestSQLiteStorage(t))
+}
+
+func TestPostgresStorage_ProtectedLink(t *testing.T) {
+	testProtectedLink(t, newTestPostgresStorage(t))
+}
+
+func TestFileStorage_RestoresPasswordHash(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "storage.json")
+
+	s, err := NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+	require.NoError(t, s.Save(ctx, URLRecord{ID: "secret01", OriginalURL: "https://yandex.ru", PasswordHash: "hash"}))
+	require.NoError(t, s.Close())
+
+	s, err = NewFileStorage(path, logger.Discard())
+	require.NoError(t, err)
+	defer s.Close()
+
+	_, err = s.UseLink(ctx, "secret01", false)
+	assert.ErrorIs(t, err, ErrPasswordRequired)
+	rec, err := s.GetRecord(ctx, "secret01")
+	require.NoError(t, err)
+	assert.Equal(t, "hash", rec.PasswordHash)
+}
+
+func TestShortenerService_UnlockURL(t *testing.T) {
+	storage := NewInMemoryStorage()
+	service := NewShortenerService(storage, newTestIDGenerator(t), 3, nil, nil, nil, logger.Discard())
+	ctx := context.Background()
+
+	id, err := service.CreateLink(ctx, "https://yandex.ru", LinkOptions{Password: "s3cret"})
+	require.NoError(t, err)
+	rec, err := storage.GetRecord(ctx, id)
+	require.NoError(t, err)
+	assert.NotEqual(t, "s3cret", rec.PasswordHash, "пароль хранится только в виде хеша")
+
+	_, err = service.GetOriginalURL(ctx, id)
+	assert.ErrorIs(t, err, ErrPasswordRequired)
+	for range unlockAttemptsLimit - 1 {
+		_, err = service.UnlockURL(ctx, id, "guess", "203.0.113.7")
+		assert.ErrorIs(t, err, ErrWrongPassword)
+	}
+	for range 2 {
+		url, err := service.UnlockURL(ctx, id, "s3cret", "203.0.113.7")
+		require.NoError(t, err, "удачные попытки в лимит не входят")
+		assert.Equal(t, "https://yandex.ru", url)
+	}
+	_, err = service.UnlockURL(ctx, id, "guess", "203.0.113.7")
+	assert.ErrorIs(t, err, ErrWrongPassword)
+	_, err = service.UnlockURL(ctx, id, "s3cret", "203.0.113.7")
+	assert.ErrorIs(t, err, ErrTooManyAttempts, "сверх лимита не проверяется даже верный пароль")
+	_, err = service.UnlockURL(ctx, id, "s3cret", "203.0.113.8")
+	require.NoError(t, err, "чужие неудачные попытки не блокируют другого клиента")
+
+	// Подбор с многих адресов ограничен общим лимитом ссылки; настоящий лимит
+	// потребовал бы слишком многих проверок bcrypt.
+	service.linkUnlocks = newAttemptLimiter(2, unlockAttemptsWindow)
+	otherID, err := service.CreateLink(ctx, "https://google.com", LinkOptions{Password: "s3cret"})
+	require.NoError(t, err)
+	for _, client := range []string{"198.51.100.1", "198.51.100.2"} {
+		_, err = service.UnlockURL(ctx, otherID, "guess", client)
+		assert.ErrorIs(t, err, ErrWrongPassword)
+	}
+	_, err = service.UnlockURL(ctx, otherID, "s3cret", "198.51.100.3")
+	assert.ErrorIs(t, err, ErrTooManyAttempts)
+
+	publicID, err := service.CreateShortURL(ctx, "https://ya.ru")
+	require.NoError(t, err)
+	url, err := service.UnlockURL(ctx, publicID, "anything", "203.0.113.7")
+	require.NoError(t, err, "у ссылки без пароля пароль не проверяется")
+	assert.Equal(t, "https://ya.ru", url)
+}
@@ -0,0 +1,281 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// PostgresStorage хранит ссылки в PostgreSQL.
+type PostgresStorage struct {
+	db *sql.DB
+}
+
+// NewPostgresStorage подключается к базе по DSN и применяет миграции схемы.
+func NewPostgresStorage(ctx context.Context, dsn string, logger *slog.Logger) (*PostgresStorage, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+	if err := migrate(ctx, db, "postgres", logger); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate postgres: %w", err)
+	}
+	return &PostgresStorage{db: db}, nil
+}
+
+// urlsOriginalURLIndex - имя уникального индекса по исходному URL (см. миграцию 0002).
+const urlsOriginalURLIndex = "urls_original_url_uidx"
+
+// mapPostgresError переводит ошибки драйвера в ошибки хранилища.
+func mapPostgresError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == pgerrcode.UniqueViolation {
+		if pgErr.ConstraintName == urlsOriginalURLIndex {
+			return ErrURLExists
+		}
+		return ErrConflict
+	}
+	return err
+}
+
+// pgInsertQuery вставляет ссылку; clicks_left при сохранении равен max_clicks.
+const pgInsertQuery = `INSERT INTO urls (short_id, original_url, user_id, expires_at, max_clicks, clicks_left, password_hash)
+	VALUES ($1, $2, $3, $4, $5, $5, $6)`
+
+// pgReleaseURLsQuery освобождает URL из массива $1 так же, как releaseURLQuery.
+const pgReleaseURLsQuery = `UPDATE urls SET url_released = TRUE
+	WHERE original_url = ANY($1) AND NOT is_deleted AND NOT url_released AND NOT ` + liveLinkCondition
+
+// Save реализует метод интерфейса Storage.
+func (s *PostgresStorage) Save(ctx context.Context, rec URLRecord) error {
+	_, err := s.insert(ctx, pgInsertQuery, rec)
+	return err
+}
+
+// SaveIfAbsent реализует интерфейс AbsentSaver.
+func (s *PostgresStorage) SaveIfAbsent(ctx context.Context, rec URLRecord) (bool, error) {
+	res, err := s.insert(ctx, pgInsertQuery+` ON CONFLICT (short_id) DO NOTHING`, rec)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return inserted == 1, nil
+}
+
+// insert выполняет вставку query. Если URL занят недействующей ссылкой, освобождает
+// его и повторяет вставку; так лишний запрос делается только при занятом URL.
+func (s *PostgresStorage) insert(ctx context.Context, query string, rec URLRecord) (sql.Result, error) {
+	args := []any{rec.ID, rec.OriginalURL, rec.UserID, nullTime(rec.ExpiresAt), nullClicks(rec.MaxClicks), rec.PasswordHash}
+	res, err := s.db.ExecContext(ctx, query, args...)
+	err = mapPostgresError(err)
+	if !errors.Is(err, ErrURLExists) {
+		return res, err
+	}
+
+	released, releaseErr := s.db.ExecContext(ctx, releaseURLQuery, rec.OriginalURL, time.Now())
+	if releaseErr != nil {
+		return nil, mapPostgresError(releaseErr)
+	}
+	if n, releaseErr := released.RowsAffected(); releaseErr != nil || n == 0 {
+		return nil, err
+	}
+	res, err = s.db.ExecContext(ctx, query, args...)
+	return res, mapPostgresError(err)
+}
+
+// SaveBatch реализует интерфейс BatchSaver: вся пачка вставляется в одной транзакции.
+// URL недействующих ссылок освобождаются заранее: после ошибки вставки транзакцию
+// PostgreSQL уже не продолжить.
+func (s *PostgresStorage) SaveBatch(ctx context.Context, records []URLRecord) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin batch transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	urls := make([]string, len(records))
+	for i, rec := range records {
+		urls[i] = rec.OriginalURL
+	}
+	if _, err := tx.ExecContext(ctx, pgReleaseURLsQuery, urls, time.Now()); err != nil {
+		return mapPostgresError(err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pgInsertQuery)
+	if err != nil {
+		return fmt.Errorf("failed to prepare batch insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, rec := range records {
+		_, err := stmt.ExecContext(ctx, rec.ID, rec.OriginalURL, rec.UserID, nullTime(rec.ExpiresAt), nullClicks(rec.MaxClicks), rec.PasswordHash)
+		if err != nil {
+			return mapPostgresError(err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetByID реализует метод интерфейса Storage.
+func (s *PostgresStorage) GetByID(ctx context.Context, id string) (string, error) {
+	rec, err := s.GetRecord(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return rec.OriginalURL, nil
+}
+
+// UseLink реализует метод интерфейса Storage. Ссылки без ограничения переходов
+// обходятся одним чтением, остаток ограниченных уменьшается условным UPDATE.
+func (s *PostgresStorage) UseLink(ctx context.Context, id string, unlocked bool) (string, error) {
+	rec, err := s.GetRecord(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if rec.Protected() && !unlocked {
+		return "", ErrPasswordRequired
+	}
+	if rec.MaxClicks == 0 {
+		return rec.OriginalURL, nil
+	}
+
+	var originalURL string
+	err = s.db.QueryRowContext(ctx, useLimitedLinkQuery, id).Scan(&originalURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrClicksExhausted
+	}
+	if err != nil {
+		return "", mapPostgresError(err)
+	}
+	return originalURL, nil
+}
+
+// GetRecord реализует метод интерфейса Storage.
+func (s *PostgresStorage) GetRecord(ctx context.Context, id string) (URLRecord, error) {
+	var (
+		rec        = URLRecord{ID: id}
+		expiresAt  sql.NullTime
+		maxClicks  sql.NullInt64
+		clicksLeft sql.NullInt64
+	)
+	err := s.db.QueryRowContext(ctx,
+		`SELECT original_url, user_id, is_deleted, expires_at, max_clicks, clicks_left, password_hash, created_at FROM urls WHERE short_id = $1`, id).
+		Scan(&rec.OriginalURL, &rec.UserID, &rec.Deleted, &expiresAt, &maxClicks, &clicksLeft, &rec.PasswordHash, &rec.CreatedAt)
+	if err != nil {
+		return URLRecord{}, mapPostgresError(err)
+	}
+	rec.ExpiresAt = expiresAt.Time
+	rec.MaxClicks, rec.ClicksLeft = maxClicks.Int64, clicksLeft.Int64
+	return rec, rec.availability(time.Now())
+}
+
+// GetByOriginalURL реализует метод интерфейса Storage.
+func (s *PostgresStorage) GetByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+	var id string
+	err := s.db.QueryRowContext(ctx, byURLQuery, originalURL, time.Now()).Scan(&id)
+	if err != nil {
+		return "", mapPostgresError(err)
+	}
+	return id, nil
+}
+
+// GetByUser реализует метод интерфейса Storage. Курсор - последний выданный urls.id.
+func (s *PostgresStorage) GetByUser(ctx context.Context, userID, cursor string, limit int) ([]URLRecord, string, error) {
+	after, err := parseCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, short_id, original_url FROM urls
+		 WHERE user_id = $1 AND id > $2 AND NOT is_deleted
+		 ORDER BY id
+		 LIMIT $3`, userID, after, limit+1)
+	if err != nil {
+		return nil, "", mapPostgresError(err)
+	}
+	defer rows.Close()
+
+	return scanUserPage(rows, userID, limit)
+}
+
+// DeleteByUser реализует метод интерфейса Storage одним UPDATE на весь пакет.
+func (s *PostgresStorage) DeleteByUser(ctx context.Context, userID string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE urls SET is_deleted = TRUE
+		 WHERE user_id = $1 AND short_id = ANY($2) AND NOT is_deleted`, userID, ids)
+	if err != nil {
+		return mapPostgresError(err)
+	}
+	return nil
+}
+
+// Exists реализует метод интерфейса Storage.
+func (s *PostgresStorage) Exists(ctx context.Context, id string) (bool, error) {
+	var exists bool
+	err := s.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM urls WHERE short_id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return false, mapPostgresError(err)
+	}
+	return exists, nil
+}
+
+// CountLinks реализует интерфейс LinkCounter.
+func (s *PostgresStorage) CountLinks(ctx context.Context) (int64, error) {
+	var n int64
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM urls WHERE NOT is_deleted`).Scan(&n)
+	if err != nil {
+		return 0, mapPostgresError(err)
+	}
+	return n, nil
+}
+
+// PurgeExpired реализует интерфейс ExpiredPurger.
+func (s *PostgresStorage) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM urls WHERE expires_at <= $1`, now)
+	if err != nil {
+		return 0, mapPostgresError(err)
+	}
+	return res.RowsAffected()
+}
+
+// AllocateIDBlock реализует интерфейс SequenceAllocator.
+func (s *PostgresStorage) AllocateIDBlock(ctx context.Context, size int64) (int64, error) {
+	var start int64
+	if err := s.db.QueryRowContext(ctx, allocateIDBlockQuery, urlsSequence, size).Scan(&start); err != nil {
+		return 0, mapPostgresError(err)
+	}
+	return start, nil
+}
+
+// Ping реализует интерфейс Pinger.
+func (s *PostgresStorage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close реализует метод интерфейса Storage.
+func (s *PostgresStorage) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// urlsSequence - имя счетчика коротких ID в таблице id_sequences.
+const urlsSequence = "urls"
+
+// allocateIDBlockQuery атомарно сдвигает счетчик $1 на $2 значений и возвращает начало
+// выделенного блока. Синтаксис одинаков для PostgreSQL и SQLite.
+const allocateIDBlockQuery = `INSERT INTO id_sequences (name, next_value) VALUES ($1, $2)
+	ON CONFLICT (name) DO UPDATE SET next_value = id_sequences.next_value + excluded.next_value
+	RETURNING next_value - $2`
+
+// useLimitedLinkQuery засчитывает переход по ссылке с ограничением, если переходы
+// еще остались. Синтаксис одинаков для PostgreSQL и SQLite.
+const useLimitedLinkQuery = `UPDATE urls SET clicks_left = clicks_left - 1
+	WHERE short_id = $1 AND clicks_left > 0
+	RETURNING original_url`
+
+// liveLinkCondition - условие, при котором ссылка занимает свой URL (см. URLRecord.releasesURL);
+// $2 - текущий момент в формате столбца expires_at.
+const liveLinkCondition = `((expires_at IS NULL OR expires_at > $2) AND (clicks_left IS NULL OR clicks_left > 0))`
+
+// byURLQuery находит ID ссылки, занимающей URL $1. Синтаксис одинаков для PostgreSQL и SQLite.
+const byURLQuery = `SELECT short_id FROM urls
+	WHERE original_url = $1 AND NOT is_deleted AND NOT url_released AND ` + liveLinkCondition
+
+// releaseURLQuery освобождает URL $1, если его занимает недействующая ссылка, чтобы
+// уникальный индекс позволил сохранить этот URL заново. Синтаксис одинаков для
+// PostgreSQL и SQLite.
+const releaseURLQuery = `UPDATE urls SET url_released = TRUE
+	WHERE original_url = $1 AND NOT is_deleted AND NOT url_released AND NOT ` + liveLinkCondition
+
+// nullClicks переводит ограничение переходов в значение столбцов max_clicks и clicks_left: 0 - NULL.
+func nullClicks(maxClicks int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: maxClicks, Valid: maxClicks > 0}
+}
+
+// nullTime переводит срок действия в значение столбца TIMESTAMPTZ: нулевое время - NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// nullUnixMilli переводит срок действия в значение столбца INTEGER с Unix-миллисекундами:
+// нулевое время - NULL.
+func nullUnixMilli(t time.Time) sql.NullInt64 {
+	return sql.NullInt64{Int64: t.UnixMilli(), Valid: !t.IsZero()}
+}
+
+// scanUserPage читает страницу ссылок пользователя, запрошенную с LIMIT limit+1:
+// лишняя строка означает, что есть следующая страница, курсором которой
+// служит urls.id последней выданной записи.
+func scanUserPage(rows *sql.Rows, userID string, limit int) ([]URLRecord, string, error) {
+	records := make([]URLRecord, 0, limit)
+	var lastPos int64
+	hasMore := false
+	for rows.Next() {
+		if len(records) == limit {
+			hasMore = true
+			break
+		}
+		rec := URLRecord{UserID: userID}
+		if err := rows.Scan(&lastPos, &rec.ID, &rec.OriginalURL); err != nil {
+			return nil, "", fmt.Errorf("failed to scan user URL: %w", err)
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to read user URLs: %w", err)
+	}
+
+	next := ""
+	if hasMore {
+		next = formatCursor(lastPos)
+	}
+	return records, next, nil
+}
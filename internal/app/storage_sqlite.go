@@ -0,0 +1,373 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLiteDSNPrefix - префикс DSN, по которому выбирается встроенное хранилище SQLite.
+const SQLiteDSNPrefix = "sqlite://"
+
+// sqliteBusyTimeoutMs - сколько ждать освобождения блокировки записи, прежде чем вернуть SQLITE_BUSY.
+const sqliteBusyTimeoutMs = 5000
+
+// SQLiteStorage хранит ссылки во встроенной базе SQLite (чистый Go, без cgo).
+type SQLiteStorage struct {
+	db          *sql.DB
+	saveStmt    *sql.Stmt
+	insertStmt  *sql.Stmt
+	getStmt     *sql.Stmt
+	byURLStmt   *sql.Stmt
+	byUserStmt  *sql.Stmt
+	existsStmt  *sql.Stmt
+	deleteStmt  *sql.Stmt
+	useStmt     *sql.Stmt
+	releaseStmt *sql.Stmt
+}
+
+// IsSQLiteDSN сообщает, указывает ли DSN на SQLite.
+func IsSQLiteDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, SQLiteDSNPrefix)
+}
+
+// NewSQLiteStorage открывает базу по DSN вида sqlite://path, включает WAL,
+// применяет миграции и подготавливает запросы.
+func NewSQLiteStorage(ctx context.Context, dsn string, logger *slog.Logger) (*SQLiteStorage, error) {
+	path := strings.TrimPrefix(dsn, SQLiteDSNPrefix)
+	if path == "" {
+		return nil, fmt.Errorf("empty sqlite database path in DSN %q", dsn)
+	}
+
+	params := url.Values{}
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", sqliteBusyTimeoutMs))
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Add("_txlock", "immediate")
+
+	db, err := sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to sqlite: %w", err)
+	}
+	if err := migrate(ctx, db, "sqlite", logger); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate sqlite: %w", err)
+	}
+
+	s := &SQLiteStorage{db: db}
+	if err := s.prepare(ctx); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SQLiteStorage) prepare(ctx context.Context) error {
+	var err error
+	s.saveStmt, err = s.db.PrepareContext(ctx,
+		`INSERT INTO urls (short_id, original_url, user_id, expires_at, max_clicks, clicks_left, password_hash)
+		 VALUES ($1, $2, $3, $4, $5, $5, $6)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare save statement: %w", err)
+	}
+	s.insertStmt, err = s.db.PrepareContext(ctx,
+		`INSERT INTO urls (short_id, original_url, user_id, expires_at, max_clicks, clicks_left, password_hash)
+		 VALUES ($1, $2, $3, $4, $5, $5, $6)
+		 ON CONFLICT (short_id) DO NOTHING`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare insert statement: %w", err)
+	}
+	s.getStmt, err = s.db.PrepareContext(ctx,
+		`SELECT original_url, user_id, is_deleted, expires_at, max_clicks, clicks_left, password_hash, created_at FROM urls WHERE short_id = $1`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare get statement: %w", err)
+	}
+	s.byURLStmt, err = s.db.PrepareContext(ctx, byURLQuery)
+	if err != nil {
+		return fmt.Errorf("failed to prepare get by url statement: %w", err)
+	}
+	s.byUserStmt, err = s.db.PrepareContext(ctx,
+		`SELECT id, short_id, original_url FROM urls
+		 WHERE user_id = $1 AND id > $2 AND NOT is_deleted
+		 ORDER BY id
+		 LIMIT $3`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare get by user statement: %w", err)
+	}
+	s.existsStmt, err = s.db.PrepareContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM urls WHERE short_id = $1)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare exists statement: %w", err)
+	}
+	s.deleteStmt, err = s.db.PrepareContext(ctx,
+		`UPDATE urls SET is_deleted = TRUE
+		 WHERE user_id = $1 AND short_id = $2 AND NOT is_deleted`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare delete statement: %w", err)
+	}
+	s.useStmt, err = s.db.PrepareContext(ctx, useLimitedLinkQuery)
+	if err != nil {
+		return fmt.Errorf("failed to prepare use link statement: %w", err)
+	}
+	s.releaseStmt, err = s.db.PrepareContext(ctx, releaseURLQuery)
+	if err != nil {
+		return fmt.Errorf("failed to prepare release url statement: %w", err)
+	}
+	return nil
+}
+
+// mapSQLiteError переводит ошибки драйвера в ошибки хранилища.
+func mapSQLiteError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	var liteErr *sqlite.Error
+	if errors.As(err, &liteErr) {
+		switch liteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			// SQLite не сообщает имя индекса, только столбцы: "UNIQUE constraint failed: urls.original_url".
+			if strings.Contains(liteErr.Error(), "urls.original_url") {
+				return ErrURLExists
+			}
+			return ErrConflict
+		}
+	}
+	return err
+}
+
+// Save реализует метод интерфейса Storage.
+// Уникальный индекс гарантирует, что при гонке один из вызовов получит ErrConflict.
+func (s *SQLiteStorage) Save(ctx context.Context, rec URLRecord) error {
+	_, err := s.insert(ctx, s.saveStmt, rec)
+	return err
+}
+
+// SaveIfAbsent реализует интерфейс AbsentSaver.
+func (s *SQLiteStorage) SaveIfAbsent(ctx context.Context, rec URLRecord) (bool, error) {
+	res, err := s.insert(ctx, s.insertStmt, rec)
+	if err != nil {
+		return false, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return inserted == 1, nil
+}
+
+// insert выполняет вставку stmt. Если URL занят недействующей ссылкой, освобождает
+// его и повторяет вставку; так лишний запрос делается только при занятом URL.
+func (s *SQLiteStorage) insert(ctx context.Context, stmt *sql.Stmt, rec URLRecord) (sql.Result, error) {
+	args := []any{rec.ID, rec.OriginalURL, rec.UserID, nullUnixMilli(rec.ExpiresAt), nullClicks(rec.MaxClicks), rec.PasswordHash}
+	res, err := stmt.ExecContext(ctx, args...)
+	err = mapSQLiteError(err)
+	if !errors.Is(err, ErrURLExists) {
+		return res, err
+	}
+
+	released, releaseErr := s.releaseStmt.ExecContext(ctx, rec.OriginalURL, time.Now().UnixMilli())
+	if releaseErr != nil {
+		return nil, mapSQLiteError(releaseErr)
+	}
+	if n, releaseErr := released.RowsAffected(); releaseErr != nil || n == 0 {
+		return nil, err
+	}
+	res, err = stmt.ExecContext(ctx, args...)
+	return res, mapSQLiteError(err)
+}
+
+// SaveBatch реализует интерфейс BatchSaver: вся пачка вставляется в одной транзакции.
+// URL недействующих ссылок освобождаются перед вставкой каждой записи.
+func (s *SQLiteStorage) SaveBatch(ctx context.Context, records []URLRecord) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin batch transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt := tx.StmtContext(ctx, s.saveStmt)
+	defer stmt.Close()
+	release := tx.StmtContext(ctx, s.releaseStmt)
+	defer release.Close()
+
+	now := time.Now().UnixMilli()
+	for _, rec := range records {
+		if _, err := release.ExecContext(ctx, rec.OriginalURL, now); err != nil {
+			return mapSQLiteError(err)
+		}
+		_, err := stmt.ExecContext(ctx, rec.ID, rec.OriginalURL, rec.UserID,
+			nullUnixMilli(rec.ExpiresAt), nullClicks(rec.MaxClicks), rec.PasswordHash)
+		if err != nil {
+			return mapSQLiteError(err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetByID реализует метод интерфейса Storage.
+func (s *SQLiteStorage) GetByID(ctx context.Context, id string) (string, error) {
+	rec, err := s.GetRecord(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	return rec.OriginalURL, nil
+}
+
+// UseLink реализует метод интерфейса Storage. Ссылки без ограничения переходов
+// обходятся одним чтением, остаток ограниченных уменьшается условным UPDATE.
+func (s *SQLiteStorage) UseLink(ctx context.Context, id string, unlocked bool) (string, error) {
+	rec, err := s.GetRecord(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if rec.Protected() && !unlocked {
+		return "", ErrPasswordRequired
+	}
+	if rec.MaxClicks == 0 {
+		return rec.OriginalURL, nil
+	}
+
+	var originalURL string
+	err = s.useStmt.QueryRowContext(ctx, id).Scan(&originalURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrClicksExhausted
+	}
+	if err != nil {
+		return "", mapSQLiteError(err)
+	}
+	return originalURL, nil
+}
+
+// GetRecord реализует метод интерфейса Storage.
+func (s *SQLiteStorage) GetRecord(ctx context.Context, id string) (URLRecord, error) {
+	var (
+		rec        = URLRecord{ID: id}
+		expiresAt  sql.NullInt64
+		maxClicks  sql.NullInt64
+		clicksLeft sql.NullInt64
+		createdAt  string
+	)
+	err := s.getStmt.QueryRowContext(ctx, id).Scan(&rec.OriginalURL, &rec.UserID, &rec.Deleted, &expiresAt, &maxClicks, &clicksLeft, &rec.PasswordHash,
+		&createdAt)
+	if err != nil {
+		return URLRecord{}, mapSQLiteError(err)
+	}
+	// created_at заполняет CURRENT_TIMESTAMP: время UTC с точностью до секунды.
+	rec.CreatedAt, err = time.Parse(time.DateTime, createdAt)
+	if err != nil {
+		return URLRecord{}, fmt.Errorf("invalid created_at of %s: %w", id, err)
+	}
+	if expiresAt.Valid {
+		rec.ExpiresAt = time.UnixMilli(expiresAt.Int64)
+	}
+	rec.MaxClicks, rec.ClicksLeft = maxClicks.Int64, clicksLeft.Int64
+	return rec, rec.availability(time.Now())
+}
+
+// GetByOriginalURL реализует метод интерфейса Storage.
+func (s *SQLiteStorage) GetByOriginalURL(ctx context.Context, originalURL string) (string, error) {
+	var id string
+	if err := s.byURLStmt.QueryRowContext(ctx, originalURL, time.Now().UnixMilli()).Scan(&id); err != nil {
+		return "", mapSQLiteError(err)
+	}
+	return id, nil
+}
+
+// GetByUser реализует метод интерфейса Storage. Курсор - последний выданный urls.id.
+func (s *SQLiteStorage) GetByUser(ctx context.Context, userID, cursor string, limit int) ([]URLRecord, string, error) {
+	after, err := parseCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	rows, err := s.byUserStmt.QueryContext(ctx, userID, after, limit+1)
+	if err != nil {
+		return nil, "", mapSQLiteError(err)
+	}
+	defer rows.Close()
+
+	return scanUserPage(rows, userID, limit)
+}
+
+// DeleteByUser реализует метод интерфейса Storage: пакет помечается удаленным в одной транзакции.
+func (s *SQLiteStorage) DeleteByUser(ctx context.Context, userID string, ids []string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin delete transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt := tx.StmtContext(ctx, s.deleteStmt)
+	defer stmt.Close()
+
+	for _, id := range ids {
+		if _, err := stmt.ExecContext(ctx, userID, id); err != nil {
+			return mapSQLiteError(err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Exists реализует метод интерфейса Storage.
+func (s *SQLiteStorage) Exists(ctx context.Context, id string) (bool, error) {
+	var exists bool
+	if err := s.existsStmt.QueryRowContext(ctx, id).Scan(&exists); err != nil {
+		return false, mapSQLiteError(err)
+	}
+	return exists, nil
+}
+
+// CountLinks реализует интерфейс LinkCounter.
+func (s *SQLiteStorage) CountLinks(ctx context.Context) (int64, error) {
+	var n int64
+	err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM urls WHERE NOT is_deleted`).Scan(&n)
+	if err != nil {
+		return 0, mapSQLiteError(err)
+	}
+	return n, nil
+}
+
+// PurgeExpired реализует интерфейс ExpiredPurger.
+func (s *SQLiteStorage) PurgeExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM urls WHERE expires_at <= $1`, now.UnixMilli())
+	if err != nil {
+		return 0, mapSQLiteError(err)
+	}
+	return res.RowsAffected()
+}
+
+// AllocateIDBlock реализует интерфейс SequenceAllocator.
+func (s *SQLiteStorage) AllocateIDBlock(ctx context.Context, size int64) (int64, error) {
+	var start int64
+	if err := s.db.QueryRowContext(ctx, allocateIDBlockQuery, urlsSequence, size).Scan(&start); err != nil {
+		return 0, mapSQLiteError(err)
+	}
+	return start, nil
+}
+
+// Ping реализует интерфейс Pinger.
+func (s *SQLiteStorage) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close реализует метод интерфейса Storage.
+func (s *SQLiteStorage) Close() error {
+	for _, stmt := range []*sql.Stmt{s.saveStmt, s.insertStmt, s.getStmt, s.byURLStmt, s.byUserStmt, s.existsStmt, s.deleteStmt, s.useStmt, s.releaseStmt} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+	return s.db.Close()
+}
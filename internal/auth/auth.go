@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// userIDBytes - длина случайного ID пользователя в байтах (в hex вдвое длиннее).
+const userIDBytes = 16
+
+var (
+	ErrEmptyKey     = errors.New("signing key must not be empty")
+	ErrInvalidToken = errors.New("invalid signed token")
+)
+
+// Signer подписывает и проверяет ID пользователя с помощью HMAC-SHA256.
+// Подписывает всегда текущим ключом, а проверяет текущим и предыдущими,
+// что позволяет менять ключ без разлогинивания пользователей.
+type Signer struct {
+	keys [][]byte
+}
+
+// NewSigner создает Signer с текущим ключом и необязательными предыдущими ключами.
+// Пустые предыдущие ключи игнорируются.
+func NewSigner(current string, previous ...string) (*Signer, error) {
+	if current == "" {
+		return nil, ErrEmptyKey
+	}
+	keys := [][]byte{[]byte(current)}
+	for _, key := range previous {
+		if key != "" {
+			keys = append(keys, []byte(key))
+		}
+	}
+	return &Signer{keys: keys}, nil
+}
+
+// Sign возвращает токен вида "<userID>.<hex(hmac)>", подписанный текущим ключом.
+func (s *Signer) Sign(userID string) string {
+	return userID + "." + hex.EncodeToString(mac(s.keys[0], userID))
+}
+
+// Verify проверяет токен и возвращает ID пользователя.
+// rotated == true, если токен подписан не текущим ключом и его стоит переподписать.
+func (s *Signer) Verify(token string) (userID string, rotated bool, err error) {
+	userID, signature, found := strings.Cut(token, ".")
+	if !found || userID == "" {
+		return "", false, ErrInvalidToken
+	}
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return "", false, ErrInvalidToken
+	}
+	for i, key := range s.keys {
+		if hmac.Equal(sig, mac(key, userID)) {
+			return userID, i > 0, nil
+		}
+	}
+	return "", false, ErrInvalidToken
+}
+
+func mac(key []byte, userID string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(userID))
+	return h.Sum(nil)
+}
+
+// NewUserID генерирует случайный ID пользователя.
+func NewUserID() (string, error) {
+	b := make([]byte, userIDBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate user ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// NewRandomKey генерирует случайный ключ подписи; используется, если ключ не задан в конфигурации.
+func NewRandomKey() (string, error) {
+	b := make([]byte, sha256.Size)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+type identityKey struct{}
+
+// identity - пользователь запроса; issued == true, если ID выдан в этом же запросе.
+type identity struct {
+	userID string
+	issued bool
+}
+
+// WithUserID возвращает контекст с ID пользователя, подтвержденным подписанной cookie.
+func WithUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity{userID: userID})
+}
+
+// WithIssuedUserID возвращает контекст с ID пользователя, выданным в текущем запросе.
+// Такой пользователь еще ничего не создавал и не считается аутентифицированным.
+func WithIssuedUserID(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity{userID: userID, issued: true})
+}
+
+// UserIDFromContext возвращает ID пользователя из контекста запроса.
+func UserIDFromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(identityKey{}).(identity)
+	return id.userID, ok && id.userID != ""
+}
+
+// IsAuthenticated сообщает, пришел ли запрос с действительной cookie пользователя.
+func IsAuthenticated(ctx context.Context) bool {
+	id, ok := ctx.Value(identityKey{}).(identity)
+	return ok && id.userID != "" && !id.issued
+}
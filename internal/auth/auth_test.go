@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSigner(t *testing.T) {
+	oldSigner, err := NewSigner("old-key")
+	require.NoError(t, err)
+	signer, err := NewSigner("new-key", "old-key")
+	require.NoError(t, err)
+
+	token := signer.Sign("user-1")
+	userID, rotated, err := signer.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", userID)
+	assert.False(t, rotated)
+
+	// Токен, подписанный предыдущим ключом, принимается и помечается для переподписи.
+	userID, rotated, err = signer.Verify(oldSigner.Sign("user-2"))
+	require.NoError(t, err)
+	assert.Equal(t, "user-2", userID)
+	assert.True(t, rotated)
+
+	// Новый ключ не знаком старому подписчику.
+	_, _, err = oldSigner.Verify(token)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	for _, bad := range []string{"", "user-1", ".abcd", "user-1.zz", "user-2." + token[len("user-1."):]} {
+		_, _, err = signer.Verify(bad)
+		assert.ErrorIs(t, err, ErrInvalidToken, "token %q", bad)
+	}
+
+	_, err = NewSigner("")
+	assert.ErrorIs(t, err, ErrEmptyKey)
+}
+
+func TestUserIDContext(t *testing.T) {
+	_, ok := UserIDFromContext(context.Background())
+	assert.False(t, ok)
+
+	ctx := WithUserID(context.Background(), "user-1")
+	userID, ok := UserIDFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "user-1", userID)
+	assert.True(t, IsAuthenticated(ctx))
+
+	ctx = WithIssuedUserID(context.Background(), "user-2")
+	userID, ok = UserIDFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, "user-2", userID)
+	assert.False(t, IsAuthenticated(ctx), "только что выданный ID не аутентифицирован")
+
+	a, err := NewUserID()
+	require.NoError(t, err)
+	b, err := NewUserID()
+	require.NoError(t, err)
+	assert.NotEqual(t, a, b)
+}
@@ -2,30 +2,93 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/cmpxNot29a/shurs/internal/helper"
+)
+
+const (
+	DefaultServerAddress   = ":8080"
+	DefaultBaseURL         = "http://localhost:8080"
+	DefaultIDLength        = 8
+	DefaultAttempts        = 10
+	DefaultFileStoragePath = ""
+	DefaultDatabaseDSN     = ""
+	DefaultLogLevel        = "info"
+	DefaultLogFormat       = "text"
+	DefaultShutdownTimeout = 10 * time.Second
+	DefaultReadinessDrain  = 0
+	DefaultIDAlphabet      = helper.Base62Alphabet
+	DefaultIDEscalateAfter = 0
+	DefaultIDStrategy      = IDStrategyRandom
+	DefaultIDBlockSize     = 100
+	DefaultReapInterval    = time.Minute
 )
 
+// Стратегии генерации коротких ID.
 const (
-	DefaultServerAddress = ":8080"
-	DefaultBaseURL       = "http://localhost:8080"
-	DefaultIDLength      = 8
-	DefaultAttempts      = 10
+	IDStrategyRandom     = "random"     // случайные ID с повтором при коллизии
+	IDStrategySequential = "sequential" // счетчик из хранилища через ключевую перестановку
+	IDStrategyHash       = "hash"       // HMAC нормализованного URL: один URL - один ID
 )
 
 type Config struct {
-	ServerAddress string // Адрес запуска HTTP-сервера
-	BaseURL       string // Базовый адрес для сокращенных URL
-	IDLength      int
-	Attempts      int
+	ServerAddress     string // Адрес запуска HTTP-сервера
+	BaseURL           string // Базовый адрес для сокращенных URL
+	IDLength          int
+	IDAlphabet        string // Алфавит коротких ID
+	IDEscalateAfter   int    // Число коллизий подряд, после которого ID удлиняется; 0 - не удлинять
+	IDStrategy        string // Стратегия генерации ID: random, sequential или hash
+	IDKey             string // Ключ последовательных и хеш-ID; должен быть постоянным
+	IDBlockSize       int    // Сколько значений счетчика ID резервировать в хранилище за раз
+	Attempts          int
+	FileStoragePath   string        // Путь к файлу хранилища; пустая строка - хранение только в памяти
+	DatabaseDSN       string        // Строка подключения к БД (PostgreSQL или sqlite://path); имеет приоритет над файловым хранилищем
+	LogLevel          string        // Уровень логирования: debug, info, warn, error
+	LogFormat         string        // Формат логов: text или json
+	AuthKey           string        // Ключ подписи cookie пользователя; пустой - случайный ключ на время работы процесса
+	AuthPrevKey       string        // Предыдущий ключ подписи, принимается при ротации ключей
+	ShutdownTimeout   time.Duration // Время на завершение активных запросов и фоновых задач при остановке
+	ReadinessDrain    time.Duration // Пауза между переходом /readyz в 503 и остановкой сервера; 0 - без паузы
+	ReapInterval      time.Duration // Период удаления истекших ссылок; 0 - не удалять
+	AnalyticsFilePath string        // Путь к файлу событий переходов; пустая строка - статистика только в памяти
+	AnalyticsKey      string        // Ключ хеширования IP посетителей; пустой - случайный ключ на время работы процесса
 }
 
-func LoadConfig() *Config {
+// LoadConfig читает конфигурацию из флагов и переменных окружения; переменные окружения
+// имеют приоритет. Некорректное числовое значение или длительность в переменной окружения -
+// ошибка, как и во флаге.
+func LoadConfig() (*Config, error) {
 
 	cfg := &Config{}
 
 	flag.StringVar(&cfg.ServerAddress, "a", DefaultServerAddress, "HTTP server start address")
 	flag.StringVar(&cfg.BaseURL, "b", DefaultBaseURL, "Base address for resulting short URLs")
+	flag.StringVar(&cfg.FileStoragePath, "f", DefaultFileStoragePath, "Path to the file storage (empty to keep links in memory only)")
+	flag.StringVar(&cfg.DatabaseDSN, "d", DefaultDatabaseDSN, "Database connection string (PostgreSQL DSN or sqlite://path)")
+
+	flag.StringVar(&cfg.LogLevel, "log-level", DefaultLogLevel, "Log level: debug, info, warn, error")
+	flag.StringVar(&cfg.LogFormat, "log-format", DefaultLogFormat, "Log format: text or json")
+
+	flag.StringVar(&cfg.AuthKey, "auth-key", "", "Key for signing user cookies")
+	flag.StringVar(&cfg.AuthPrevKey, "auth-prev-key", "", "Previous key for signing user cookies, accepted during key rotation")
+
+	flag.StringVar(&cfg.IDAlphabet, "id-alphabet", DefaultIDAlphabet, "Alphabet of generated short IDs")
+	flag.IntVar(&cfg.IDEscalateAfter, "id-escalate-after", DefaultIDEscalateAfter, "Grow short IDs by one character after this many consecutive collisions (0 to disable)")
+	flag.StringVar(&cfg.IDStrategy, "id-strategy", DefaultIDStrategy, "Short ID strategy: random, sequential or hash")
+	flag.StringVar(&cfg.IDKey, "id-key", "", "Key for sequential and hash short IDs; must not change between restarts")
+	flag.IntVar(&cfg.IDBlockSize, "id-block-size", DefaultIDBlockSize, "Number of sequential ID counter values reserved in storage at once")
+
+	flag.DurationVar(&cfg.ShutdownTimeout, "shutdown-timeout", DefaultShutdownTimeout, "Time to drain in-flight requests and background workers on shutdown")
+	flag.DurationVar(&cfg.ReadinessDrain, "readiness-drain", DefaultReadinessDrain, "Delay between failing the readiness probe and stopping the server, so load balancers stop routing requests")
+	flag.StringVar(&cfg.AnalyticsFilePath, "analytics-file", "", "Path to the click analytics file (empty to keep analytics in memory only)")
+	flag.StringVar(&cfg.AnalyticsKey, "analytics-key", "", "Key for hashing visitor IP addresses in click analytics")
+
+	flag.DurationVar(&cfg.ReapInterval, "reap-interval", DefaultReapInterval, "How often expired links are purged from storage (0 to disable)")
 
 	flag.Parse()
 
@@ -40,7 +103,99 @@ func LoadConfig() *Config {
 		cfg.BaseURL = envVar
 	}
 
+	if envVar, ok := os.LookupEnv("FILE_STORAGE_PATH"); ok {
+		cfg.FileStoragePath = envVar
+	}
+
+	if envVar := os.Getenv("DATABASE_DSN"); envVar != "" {
+		cfg.DatabaseDSN = envVar
+	}
+
+	if envVar := os.Getenv("LOG_LEVEL"); envVar != "" {
+		cfg.LogLevel = envVar
+	}
+
+	if envVar := os.Getenv("LOG_FORMAT"); envVar != "" {
+		cfg.LogFormat = envVar
+	}
+
+	if envVar := os.Getenv("AUTH_KEY"); envVar != "" {
+		cfg.AuthKey = envVar
+	}
+
+	if envVar := os.Getenv("AUTH_PREV_KEY"); envVar != "" {
+		cfg.AuthPrevKey = envVar
+	}
+
+	if envVar := os.Getenv("ID_ALPHABET"); envVar != "" {
+		cfg.IDAlphabet = envVar
+	}
+
+	if err := envInt("ID_ESCALATE_AFTER", &cfg.IDEscalateAfter); err != nil {
+		return nil, err
+	}
+
+	if envVar := os.Getenv("ID_STRATEGY"); envVar != "" {
+		cfg.IDStrategy = envVar
+	}
+
+	if envVar := os.Getenv("ID_KEY"); envVar != "" {
+		cfg.IDKey = envVar
+	}
+
+	if err := envInt("ID_BLOCK_SIZE", &cfg.IDBlockSize); err != nil {
+		return nil, err
+	}
+
+	if err := envDuration("SHUTDOWN_TIMEOUT", &cfg.ShutdownTimeout); err != nil {
+		return nil, err
+	}
+
+	if err := envDuration("READINESS_DRAIN", &cfg.ReadinessDrain); err != nil {
+		return nil, err
+	}
+
+	if err := envDuration("REAP_INTERVAL", &cfg.ReapInterval); err != nil {
+		return nil, err
+	}
+
+	if envVar, ok := os.LookupEnv("ANALYTICS_FILE_PATH"); ok {
+		cfg.AnalyticsFilePath = envVar
+	}
+
+	if envVar := os.Getenv("ANALYTICS_KEY"); envVar != "" {
+		cfg.AnalyticsKey = envVar
+	}
+
 	cfg.BaseURL = strings.TrimRight(cfg.BaseURL, "/")
 
-	return cfg
+	return cfg, nil
+}
+
+// envInt записывает в dst целое значение переменной окружения name, если она задана.
+func envInt(name string, dst *int) error {
+	envVar := os.Getenv(name)
+	if envVar == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(envVar)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", name, envVar, err)
+	}
+	*dst = n
+	return nil
+}
+
+// envDuration записывает в dst длительность из переменной окружения name, если она задана.
+func envDuration(name string, dst *time.Duration) error {
+	envVar := os.Getenv(name)
+	if envVar == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(envVar)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q: %w", name, envVar, err)
+	}
+	*dst = d
+	return nil
 }
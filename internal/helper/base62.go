@@ -6,23 +6,48 @@ import (
 	"regexp"
 )
 
-func GenerateRandomBase62(length int) ([]byte, error) {
+// Base62Alphabet - алфавит коротких ID по умолчанию.
+const Base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 
-	const base62Charset = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
-	// Гарантируем, что у нас достаточно случайных байт для преобразования
-	bytes := make([]byte, length)
-	if _, err := rand.Read(bytes); err != nil {
+// GenerateRandomBase62 возвращает случайную строку указанной длины из алфавита base62.
+func GenerateRandomBase62(length int) ([]byte, error) {
+	s, err := GenerateRandomString(Base62Alphabet, length)
+	if err != nil {
 		return []byte{}, err
 	}
+	return []byte(s), nil
+}
 
-	// Преобразуем каждый байт в соответствующий символ из base62Charset
-	result := make([]byte, length)
-	charsetLen := byte(len(base62Charset))
-	for i, b := range bytes {
-		result[i] = base62Charset[b%charsetLen]
+// GenerateRandomString возвращает случайную строку указанной длины из символов alphabet
+// (от 2 до 256 однобайтовых символов) с равномерным распределением.
+// Простое b % len(alphabet) смещает распределение к первым символам, если 256 не делится
+// на длину алфавита, поэтому байты из неполного последнего "круга" отбрасываются.
+func GenerateRandomString(alphabet string, length int) (string, error) {
+	n := len(alphabet)
+	if n < 2 || n > 256 {
+		return "", fmt.Errorf("alphabet must contain from 2 to 256 characters, got %d", n)
 	}
+	// Наибольшее кратное n число, не превосходящее 256: байты не меньше limit отбрасываются.
+	limit := 256 - 256%n
 
-	return result, nil
+	result := make([]byte, 0, length)
+	// Запас на отброшенные байты, чтобы обычно хватало одного чтения.
+	buf := make([]byte, length+length/4+1)
+	for len(result) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			result = append(result, alphabet[int(b)%n])
+			if len(result) == length {
+				break
+			}
+		}
+	}
+	return string(result), nil
 }
 
 func IsValidBase62String(s string, expectedLength int) bool {
@@ -0,0 +1,37 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// New создает структурированный логгер с указанным уровнем (debug, info, warn, error)
+// и форматом (text или json).
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch strings.ToLower(format) {
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q: expected %q or %q", format, FormatText, FormatJSON)
+	}
+}
+
+// Discard возвращает логгер, который ничего не пишет. Удобен в тестах.
+func Discard() *slog.Logger {
+	return slog.New(slog.DiscardHandler)
+}
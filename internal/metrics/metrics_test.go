@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	reg := NewRegistry()
+	requests := reg.NewCounter("requests_total", "Handled requests.", "route", "status")
+	collisions := reg.NewCounter("collisions_total", "ID collisions.")
+	latency := reg.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1}, "op")
+	reg.NewGaugeFunc("links", "Stored links.", func() float64 { return 42 })
+
+	requests.Inc("/{id}", "307")
+	requests.Inc("/{id}", "307")
+	requests.Add(3, `/"quoted"`, "500")
+	latency.Observe(0.05, "create")
+	latency.Observe(0.5, "create")
+	latency.Observe(2, "create")
+
+	var b strings.Builder
+	_, err := reg.WriteTo(&b)
+	require.NoError(t, err)
+
+	expected := `# HELP requests_total Handled requests.
+# TYPE requests_total counter
+requests_total{route="/\"quoted\"",status="500"} 3
+requests_total{route="/{id}",status="307"} 2
+# HELP collisions_total ID collisions.
+# TYPE collisions_total counter
+collisions_total 0
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{op="create",le="0.1"} 1
+latency_seconds_bucket{op="create",le="1"} 2
+latency_seconds_bucket{op="create",le="+Inf"} 3
+latency_seconds_sum{op="create"} 2.55
+latency_seconds_count{op="create"} 3
+# HELP links Stored links.
+# TYPE links gauge
+links 42
+`
+	assert.Equal(t, expected, b.String())
+	assert.Equal(t, float64(2), requests.Value("/{id}", "307"))
+	assert.Equal(t, float64(0), collisions.Value())
+	assert.Equal(t, uint64(3), latency.Count("create"))
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounter("hits_total", "Hits.").Inc()
+
+	rr := httptest.NewRecorder()
+	reg.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, ContentType, rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), "hits_total 1\n")
+}
+
+func TestRegistry_Panics(t *testing.T) {
+	reg := NewRegistry()
+	c := reg.NewCounter("dup_total", "Dup.", "label")
+	assert.Panics(t, func() { reg.NewCounter("dup_total", "Dup.") }, "повторная регистрация")
+	assert.Panics(t, func() { c.Inc() }, "неверное число меток")
+}